@@ -0,0 +1,359 @@
+package manago
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionKeyAuth       = "auth"
+	sessionKeyUsername   = "username"
+	sessionKeyUserGroups = "auth_groups"
+
+	defaultLoginMaxFailures = 5
+	defaultLockoutDuration  = 15 * time.Minute
+	// usernames with failed logins tracked at once, expired entries are removed when limit is reached
+	maxTrackedFailures = 10000
+)
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+var ErrAccountLocked = errors.New("account temporarily locked after failed login attempts")
+
+// AuthUser should be implemented by application user model, GetAuthId is kept in session as Auth.Guid
+type AuthUser interface {
+	GetAuthId() string
+	GetUsername() string
+	GetPasswordHash() string
+}
+
+// GroupedUser can be implemented by AuthUser to provide groups (matched with Config AuthGroups UserGroupName)
+type GroupedUser interface {
+	GetUserGroups() []string
+}
+
+// UserStore is used by Authenticator to find user by username, should return gorm.ErrRecordNotFound when user not exists
+type UserStore interface {
+	FindUser(db *gorm.DB, username string) (AuthUser, error)
+}
+
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(hash string, password string) (bool, error)
+}
+
+type BcryptHasher struct {
+	Cost int
+}
+
+func (bh *BcryptHasher) Hash(password string) (string, error) {
+	cost := bh.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", fmt.Errorf("BcryptHasher Hash failed: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+func (bh *BcryptHasher) Verify(hash string, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("BcryptHasher Verify failed: %w", err)
+	}
+
+	return true, nil
+}
+
+// Argon2Hasher hashes with argon2id and encodes result in PHC string format ($argon2id$v=19$m=..,t=..,p=..$salt$key)
+type Argon2Hasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+func NewArgon2Hasher() *Argon2Hasher {
+	return &Argon2Hasher{
+		Time:    1,
+		Memory:  64 * 1024,
+		Threads: 4,
+		KeyLen:  32,
+		SaltLen: 16,
+	}
+}
+
+func (ah *Argon2Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, ah.SaltLen)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("Argon2Hasher Hash generating salt failed: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, ah.Time, ah.Memory, ah.Threads, ah.KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, ah.Memory, ah.Time, ah.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (ah *Argon2Hasher) Verify(hash string, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, fmt.Errorf("Argon2Hasher Verify: wrong hash format")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false, fmt.Errorf("Argon2Hasher Verify: unsupported version (%s)", parts[2])
+	}
+
+	var memory, time uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil {
+		return false, fmt.Errorf("Argon2Hasher Verify: wrong params: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("Argon2Hasher Verify: decoding salt failed: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("Argon2Hasher Verify: decoding key failed: %w", err)
+	}
+
+	compared := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, compared) == 1, nil
+}
+
+// CheckPassword verifies password against bcrypt or argon2id hash, detected by hash prefix
+func CheckPassword(hash string, password string) (bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return (&Argon2Hasher{}).Verify(hash, password)
+	}
+
+	return (&BcryptHasher{}).Verify(hash, password)
+}
+
+type loginFailures struct {
+	count       int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// expired reports if failures are older than lockout duration and username is not locked
+func (lf *loginFailures) expired(now time.Time, lockout time.Duration) bool {
+	return now.After(lf.lockedUntil) && now.Sub(lf.lastFailure) > lockout
+}
+
+// Authenticator checks user credentials using Store and locks username out after MaxFailures failed attempts in a row
+type Authenticator struct {
+	Store           UserStore
+	Hasher          PasswordHasher
+	MaxFailures     int
+	LockoutDuration time.Duration
+
+	failures map[string]*loginFailures
+	lock     sync.Mutex
+
+	// dummyHash is checked when user is not found, so response time does not reveal existing usernames
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+func NewAuthenticator(conf AuthConfig) *Authenticator {
+	au := &Authenticator{
+		MaxFailures:     defaultLoginMaxFailures,
+		LockoutDuration: defaultLockoutDuration,
+		failures:        make(map[string]*loginFailures),
+	}
+
+	if strings.EqualFold(conf.Hasher, "argon2") {
+		au.Hasher = NewArgon2Hasher()
+	} else {
+		au.Hasher = &BcryptHasher{}
+	}
+
+	if conf.MaxFailures != 0 {
+		au.MaxFailures = conf.MaxFailures
+	}
+	if conf.LockoutMinutes > 0 {
+		au.LockoutDuration = time.Duration(conf.LockoutMinutes) * time.Minute
+	}
+
+	return au
+}
+
+// HashPassword hashes password with configured Hasher
+func (au *Authenticator) HashPassword(password string) (string, error) {
+	return au.Hasher.Hash(password)
+}
+
+// Authenticate finds user by username and verifies password, returns ErrInvalidCredentials or ErrAccountLocked on failure
+func (au *Authenticator) Authenticate(db *gorm.DB, username string, password string) (user AuthUser, err error) {
+	if au.Store == nil {
+		err = fmt.Errorf("Authenticator Authenticate: no user store set")
+		return
+	}
+
+	if au.isLocked(username) {
+		err = ErrAccountLocked
+		return
+	}
+
+	user, err = au.Store.FindUser(db, username)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && user == nil) {
+		au.checkDummy(password)
+		au.registerFailure(username)
+		user, err = nil, ErrInvalidCredentials
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("Authenticator Authenticate: finding user failed: %w", err)
+		return
+	}
+
+	valid, err := CheckPassword(user.GetPasswordHash(), password)
+	if err != nil || !valid {
+		if err != nil {
+			err = fmt.Errorf("Authenticator Authenticate: %w (%v)", ErrInvalidCredentials, err)
+		} else {
+			err = ErrInvalidCredentials
+		}
+		au.registerFailure(username)
+		user = nil
+		return
+	}
+
+	au.resetFailures(username)
+	return
+}
+
+// checkDummy verifies password against hash of random password, taking as long as check of existing user
+func (au *Authenticator) checkDummy(password string) {
+	au.dummyHashOnce.Do(func() {
+		random := make([]byte, 16)
+		rand.Read(random)
+		au.dummyHash, _ = au.Hasher.Hash(base64.RawStdEncoding.EncodeToString(random))
+	})
+
+	CheckPassword(au.dummyHash, password)
+}
+
+func (au *Authenticator) isLocked(username string) bool {
+	au.lock.Lock()
+	defer au.lock.Unlock()
+
+	key := strings.ToLower(username)
+	lf, present := au.failures[key]
+	if !present {
+		return false
+	}
+
+	now := time.Now()
+	if lf.expired(now, au.LockoutDuration) {
+		delete(au.failures, key)
+		return false
+	}
+
+	return now.Before(lf.lockedUntil)
+}
+
+func (au *Authenticator) registerFailure(username string) {
+	if au.MaxFailures < 1 {
+		return
+	}
+
+	au.lock.Lock()
+	defer au.lock.Unlock()
+
+	now := time.Now()
+	key := strings.ToLower(username)
+	lf, present := au.failures[key]
+	if present && lf.expired(now, au.LockoutDuration) {
+		lf.count = 0
+	}
+	if !present {
+		if len(au.failures) >= maxTrackedFailures {
+			au.removeExpired(now)
+		}
+		if len(au.failures) >= maxTrackedFailures {
+			return
+		}
+		lf = &loginFailures{}
+		au.failures[key] = lf
+	}
+
+	lf.lastFailure = now
+	lf.count++
+	if lf.count >= au.MaxFailures {
+		lf.lockedUntil = time.Now().Add(au.LockoutDuration)
+		lf.count = 0
+	}
+}
+
+// removeExpired drops failures older than lockout duration, called with lock held
+func (au *Authenticator) removeExpired(now time.Time) {
+	for key, lf := range au.failures {
+		if lf.expired(now, au.LockoutDuration) {
+			delete(au.failures, key)
+		}
+	}
+}
+
+func (au *Authenticator) resetFailures(username string) {
+	au.lock.Lock()
+	defer au.lock.Unlock()
+
+	delete(au.failures, strings.ToLower(username))
+}
+
+// AuthorizeGroups middleware allows method only for logged in users belonging to one of groups
+// from "groups" param (comma separated AuthGroup names), eg.:
+// ctr.SetMiddlewareParams(&manago.AuthorizeGroups{}, map[string]string{"groups": "admin,editor"}, "Edit")
+type AuthorizeGroups struct{}
+
+func (ag *AuthorizeGroups) RunBefore(ctr Controlled, params map[string]string) bool {
+	auth := ctr.GetAuth()
+	if !auth.IsIn {
+		ctr.SetError(403, fmt.Errorf("AuthorizeGroups: no user logged in"), "Access denied")
+		return false
+	}
+
+	groups := []string{}
+	for _, name := range strings.Split(params["groups"], ",") {
+		name = strings.TrimSpace(name)
+		if len(name) > 0 {
+			groups = append(groups, name)
+		}
+	}
+
+	if len(groups) == 0 || auth.Can(groups...) {
+		return true
+	}
+
+	ctr.SetError(403, fmt.Errorf("AuthorizeGroups: user %s not in any of groups %v", auth.Username, groups), "Access denied")
+	return false
+}
+
+func (ag *AuthorizeGroups) RunAfter(ctr Controlled, params map[string]string) {}
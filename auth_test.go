@@ -0,0 +1,143 @@
+package manago
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testUser struct {
+	name string
+	hash string
+}
+
+func (tu *testUser) GetAuthId() string       { return tu.name }
+func (tu *testUser) GetUsername() string     { return tu.name }
+func (tu *testUser) GetPasswordHash() string { return tu.hash }
+
+type testUserStore map[string]*testUser
+
+func (ts testUserStore) FindUser(db *gorm.DB, username string) (AuthUser, error) {
+	user, found := ts[username]
+	if !found {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func TestPasswordHashers(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		prefix string
+	}{
+		{"bcrypt", &BcryptHasher{Cost: bcrypt.MinCost}, "$2a$"},
+		{"argon2", &Argon2Hasher{Time: 1, Memory: 1024, Threads: 1, KeyLen: 16, SaltLen: 8}, "$argon2id$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash("secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Errorf("hash %q does not start with %q", hash, tt.prefix)
+			}
+
+			for password, want := range map[string]bool{"secret": true, "Secret": false, "": false} {
+				valid, err := CheckPassword(hash, password)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if valid != want {
+					t.Errorf("CheckPassword(%q) = %v, want %v", password, valid, want)
+				}
+			}
+		})
+	}
+}
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	hasher := &BcryptHasher{Cost: bcrypt.MinCost}
+	hash, err := hasher.Hash("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	au := NewAuthenticator(AuthConfig{MaxFailures: 3})
+	au.Hasher = hasher
+	au.Store = testUserStore{"john": &testUser{name: "john", hash: hash}}
+
+	return au
+}
+
+func TestAuthenticate(t *testing.T) {
+	au := newTestAuthenticator(t)
+
+	steps := []struct {
+		username, password string
+		wantErr            error
+	}{
+		{"john", "secret", nil},
+		{"nobody", "secret", ErrInvalidCredentials},
+		{"john", "wrong", ErrInvalidCredentials},
+		{"john", "wrong", ErrInvalidCredentials},
+		{"John", "wrong", ErrInvalidCredentials},
+		{"john", "secret", ErrAccountLocked},
+	}
+
+	for ix, step := range steps {
+		user, err := au.Authenticate(nil, step.username, step.password)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("step %d: err = %v, want %v", ix, err, step.wantErr)
+		}
+		if (err == nil) != (user != nil) {
+			t.Fatalf("step %d: user = %v with err %v", ix, user, err)
+		}
+	}
+
+	au.failures["john"].lockedUntil = time.Now().Add(-time.Second)
+	au.failures["john"].lastFailure = time.Now().Add(-au.LockoutDuration - time.Second)
+	if _, err := au.Authenticate(nil, "john", "secret"); err != nil {
+		t.Fatalf("after lockout expired: %v", err)
+	}
+	if _, present := au.failures["john"]; present {
+		t.Error("failures not removed after successful login")
+	}
+}
+
+func TestAuthenticatorFailuresBounded(t *testing.T) {
+	au := newTestAuthenticator(t)
+	old := time.Now().Add(-au.LockoutDuration - time.Minute)
+	for ix := 0; ix < maxTrackedFailures; ix++ {
+		au.failures[fmt.Sprintf("user%d", ix)] = &loginFailures{count: 1, lastFailure: old}
+	}
+
+	au.registerFailure("attacker")
+	if len(au.failures) != 1 {
+		t.Errorf("tracked failures = %d, want expired entries removed", len(au.failures))
+	}
+
+	au.failures = make(map[string]*loginFailures)
+	for ix := 0; ix < maxTrackedFailures; ix++ {
+		au.failures[fmt.Sprintf("user%d", ix)] = &loginFailures{count: 1, lastFailure: time.Now()}
+	}
+	au.registerFailure("another")
+	if len(au.failures) != maxTrackedFailures {
+		t.Errorf("tracked failures = %d, exceeds limit %d", len(au.failures), maxTrackedFailures)
+	}
+}
+
+func TestAuthenticateUnknownUserChecksHash(t *testing.T) {
+	au := newTestAuthenticator(t)
+	au.Authenticate(nil, "nobody", "secret")
+	if len(au.dummyHash) == 0 {
+		t.Error("unknown user was rejected without checking password hash")
+	}
+}
@@ -0,0 +1,129 @@
+package manago
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+const defaultMaxBodyBytes = 10 << 20
+
+// Bind fills target (pointer to struct) from request body depending on Content-Type: json is decoded
+// (unknown fields are rejected unless Config.BindAllowUnknownFields), urlencoded and multipart forms are filled
+// with FillForm. Target is validated afterwards (see Validate), errors of json body are keyed by json field names.
+// Malformed, too large or unsupported body sets error (400, 413, 415), field errors are set as validation errors
+// (see SetValidationErrors), in both cases Bind returns false.
+func (ctr *Controller) Bind(target interface{}) bool {
+	r := ctr.Req.R
+	if r == nil {
+		ctr.SetError(500, fmt.Errorf("Controller Bind: no request"))
+		return false
+	}
+
+	maxBytes := ctr.Man.maxBodyBytes()
+
+	errs := ValidationErrors{}
+	fieldName := formFieldName
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if !ctr.bindJson(target, maxBytes, errs) {
+			return false
+		}
+		fieldName = jsonFieldName
+
+	case mediaType == "multipart/form-data":
+		r.Body = http.MaxBytesReader(nil, r.Body, maxBytes)
+		err := r.ParseMultipartForm(maxBytes)
+		if err != nil {
+			setBodyError(ctr, err)
+			return false
+		}
+		_, errs = FillForm(target, r.Form)
+
+	case mediaType == "application/x-www-form-urlencoded" || len(mediaType) == 0:
+		// already parsed in Request SetData
+		_, errs = FillForm(target, r.Form)
+
+	default:
+		ctr.SetError(http.StatusUnsupportedMediaType, fmt.Errorf("Controller Bind: unsupported content type: %s", mediaType))
+		return false
+	}
+
+	for field, msg := range validateNamed(target, fieldName) {
+		errs.Add(field, msg)
+	}
+
+	if len(errs) > 0 {
+		ctr.SetValidationErrors(errs)
+		return false
+	}
+
+	return true
+}
+
+func (ctr *Controller) bindJson(target interface{}, maxBytes int64, errs ValidationErrors) bool {
+	r := ctr.Req.R
+	r.Body = http.MaxBytesReader(nil, r.Body, maxBytes)
+
+	decoder := json.NewDecoder(r.Body)
+	if !ctr.Man.Config.BindAllowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(target)
+	if err == nil && decoder.More() {
+		err = fmt.Errorf("unexpected data after json object")
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &typeErr) && len(typeErr.Field) > 0:
+		// Field is path of json names, eg. "address.zip"
+		errs.Add(typeErr.Field, fmt.Sprintf("must be %s", typeErr.Type.String()))
+		return true
+	case errors.Is(err, io.EOF):
+		ctr.SetError(http.StatusBadRequest, fmt.Errorf("Controller Bind: empty request body"), "Request body is empty")
+	default:
+		setBodyError(ctr, err)
+	}
+
+	return false
+}
+
+// maxBodyBytes returns Config.MaxBodyBytes or default limit
+func (man *Manager) maxBodyBytes() int64 {
+	if man.Config.MaxBodyBytes > 0 {
+		return man.Config.MaxBodyBytes
+	}
+
+	return defaultMaxBodyBytes
+}
+
+// jsonFieldName returns name of field in json (from json tag or field name)
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if len(name) == 0 || name == "-" {
+		return field.Name
+	}
+
+	return name
+}
+
+func setBodyError(ctr Controlled, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		ctr.SetError(http.StatusRequestEntityTooLarge, err, fmt.Sprintf("Request body too large (limit %d bytes)", maxErr.Limit))
+		return
+	}
+
+	ctr.SetError(http.StatusBadRequest, fmt.Errorf("reading request body failed: %w", err), "Malformed request body: "+err.Error())
+}
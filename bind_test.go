@@ -0,0 +1,77 @@
+package manago
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bindAddress struct {
+	Zip string `json:"zip"`
+}
+
+type bindModel struct {
+	UserName string      `json:"userName" validate:"required,min=3"`
+	Age      int         `json:"age,omitempty" validate:"max=130"`
+	Nick     string      `validate:"max=5"`
+	Address  bindAddress `json:"address"`
+}
+
+func newBindController(contentType, body string) *Controller {
+	r := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	if len(contentType) > 0 {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	ctr := &Controller{Man: &Manager{Config: Config{MaxBodyBytes: 64}}}
+	ctr.SetReqData(r, nil)
+	return ctr
+}
+
+func TestBind(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		ok          bool
+		code        int
+		fields      ValidationErrors
+		want        bindModel
+	}{
+		{"json", "application/json", `{"userName":"john","age":30}`, true, 0, nil, bindModel{UserName: "john", Age: 30}},
+		{"json errors keyed by json names", "application/json", `{"userName":"jo","age":131,"Nick":"johnny"}`, false, 0,
+			ValidationErrors{"userName": "must be at least 3 characters long", "age": "must be at most 130", "Nick": "must be at most 5 characters long"}, bindModel{}},
+		{"json type error", "application/json", `{"userName":"john","address":{"zip":5}}`, false, 0,
+			ValidationErrors{"address.zip": "must be string"}, bindModel{}},
+		{"json unknown field", "application/json", `{"userName":"john","admin":true}`, false, 400, nil, bindModel{}},
+		{"json too large", "application/json", `{"userName":"` + strings.Repeat("a", 100) + `"}`, false, 413, nil, bindModel{}},
+		{"empty json", "application/json", ``, false, 400, nil, bindModel{}},
+		{"form errors keyed by form names", "application/x-www-form-urlencoded", `user_name=jo&age=x`, false, 0,
+			ValidationErrors{"user_name": "must be at least 3 characters long", "age": "must be a whole number"}, bindModel{}},
+		{"form", "application/x-www-form-urlencoded", `user_name=john&nick=jo`, true, 0, nil, bindModel{UserName: "john", Nick: "jo"}},
+		{"unsupported", "text/plain", `john`, false, 415, nil, bindModel{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctr := newBindController(tt.contentType, tt.body)
+			got := bindModel{}
+
+			ok := ctr.Bind(&got)
+			if ok != tt.ok {
+				t.Fatalf("Bind = %v, want %v (error: %v)", ok, tt.ok, ctr.E.Err)
+			}
+			if ctr.E.Code != tt.code {
+				t.Errorf("error code = %d, want %d", ctr.E.Code, tt.code)
+			}
+			if tt.fields != nil && !reflect.DeepEqual(ctr.GetValidationErrors(), tt.fields) {
+				t.Errorf("field errors = %v, want %v", ctr.GetValidationErrors(), tt.fields)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("model = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package manago
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -14,6 +16,11 @@ type Client struct {
 }
 
 func (cl *Client) Call(path string, params url.Values, result interface{}) error {
+	return cl.CallContext(context.Background(), path, params, result)
+}
+
+// CallContext works like Call, request is cancelled when ctx is done
+func (cl *Client) CallContext(ctx context.Context, path string, params url.Values, result interface{}) error {
 	params.Add("api_key", cl.ApiKey)
 
 	netClient := &http.Client{
@@ -30,7 +37,13 @@ func (cl *Client) Call(path string, params url.Values, result interface{}) error
 		return err
 	}
 
-	resp, err := netClient.PostForm(relUrl.String(), params)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, relUrl.String(), strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := netClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -2,7 +2,7 @@ package controllers
 
 import (
 	// "fmt"
-	"manago"
+	"github.com/hubertat/manago"
 )
 
 type Sample struct {
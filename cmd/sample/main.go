@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"log"
-	"time"
 	"flag"
 
-	"manago"
-	"manago/cmd/sample/controllers"	
+	app "github.com/hubertat/manago"
+	"github.com/hubertat/manago/cmd/sample/controllers"	
 )
 
 
@@ -57,10 +57,9 @@ func main() {
 	    }
     }
     
-    log.Print(gotech.Start())
-
-    for {
-    	time.Sleep(100 * time.Second)
+    err = gotech.Run(context.Background())
+    if err != nil {
+    	log.Fatalf("Application Run failed:\n%v", err)
     }
 }
 
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/hubertat/manago/logging"
 )
@@ -29,6 +31,59 @@ type DatabaseConfig struct {
 	Pass       string
 	Name       string
 	DisableSsl bool
+
+	// sqlite only: journal in WAL mode and busy timeout in milliseconds (5000 if not set)
+	SqliteWal         bool `json:"sqlite_wal,omitempty"`
+	SqliteBusyTimeout uint `json:"sqlite_busy_timeout,omitempty"`
+
+	// connection pool settings, database/sql defaults are used when not set
+	MaxOpenConns           int  `json:"max_open_conns,omitempty"`
+	MaxIdleConns           int  `json:"max_idle_conns,omitempty"`
+	ConnMaxLifetimeMinutes uint `json:"conn_max_lifetime_minutes,omitempty"`
+}
+
+type AuthConfig struct {
+	// "bcrypt" (default) or "argon2"
+	Hasher string
+	// failed logins in a row before username is locked out (default 5, negative disables), for LockoutMinutes (default 15)
+	MaxFailures    int
+	LockoutMinutes uint
+	// when enabled session cookie is persistent only when user logged in with remember me
+	RememberMe bool
+}
+
+// CronConfig declares cron task, Schedule accepts expressions of ParseSchedule (eg. "*/15 * * * *", "@daily", "every 1h"),
+// Timezone is IANA name (eg. "Europe/Warsaw"), local time when empty
+type CronConfig struct {
+	Controller string
+	Method     string
+	Schedule   string
+	Enabled    *bool  `json:",omitempty"`
+	Timezone   string `json:",omitempty"`
+
+	// "skip" (default), "queue" or "allow", see OverlapPolicy
+	Overlap        string `json:",omitempty"`
+	TimeoutSeconds uint   `json:"timeout_seconds,omitempty"`
+}
+
+// JobsConfig sets job queue workers started with Manager (none when Workers is 0), queue is polled every PollSeconds (5 if not set),
+// running job is locked for LockSeconds (300 if not set), after that it is taken over as left by dead instance
+type JobsConfig struct {
+	Workers     int
+	PollSeconds uint `json:"poll_seconds,omitempty"`
+	LockSeconds uint `json:"lock_seconds,omitempty"`
+}
+
+// SessionConfig selects session Store: "memory" (default), "db" (Manager database, table manago_sessions)
+// or "file" (FilePath directory, TmpPath/sessions if not set); CookieSameSite is "lax" (default), "strict" or "none"
+type SessionConfig struct {
+	Store              string
+	FilePath           string `json:",omitempty"`
+	CookieName         string `json:",omitempty"`
+	CookieDomain       string `json:",omitempty"`
+	CookieSecure       bool
+	CookieSameSite     string `json:",omitempty"`
+	IdleTimeoutMinutes uint   `json:"idle_timeout_minutes,omitempty"`
 }
 
 type AuthGroup struct {
@@ -55,20 +110,44 @@ type Config struct {
 	StaticPath      string
 	WebStaticPath   string
 	ForceLiveStatic bool
+	// StaticOverlay serves files from StaticPath on disk over FS passed to NewFS or ReloadStaticFS
+	StaticOverlay bool `json:",omitempty"`
 
+	Auth       AuthConfig
 	AuthGroups []AuthGroup
 	MappedAuth map[string]*AuthGroup
 
 	DevSkipMiddleware bool
 
+	// ExclusiveLocks selects locks for Manager Exclusive routes: "memory" (default) or "db" (postgres, mssql advisory locks)
+	ExclusiveLocks          string `json:"exclusive_locks,omitempty"`
+	ExclusiveTimeoutSeconds uint   `json:"exclusive_timeout_seconds,omitempty"`
+
+	// Csrf enables csrf token check for unsafe (POST, PUT, PATCH, DELETE) requests
+	Csrf bool
+
+	// request body limit for Controller Bind and csrf check (10MB if not set), json with unknown fields is rejected unless allowed
+	MaxBodyBytes           int64 `json:"max_body_bytes,omitempty"`
+	BindAllowUnknownFields bool  `json:"bind_allow_unknown_fields,omitempty"`
+
 	ApiKey  *string
 	Clients map[string]Client
 
 	SlackHook *string `json:",omitempty"`
 
+	// Cron tasks appended to Manager CronTasks
+	Cron []CronConfig `json:",omitempty"`
+	// CronLock "db" makes every scheduled run execute once across instances sharing database,
+	// run holds lease for CronLeaseSeconds (60 if not set) renewed while running, expired lease of dead instance can be taken over
+	CronLock         string `json:"cron_lock,omitempty"`
+	CronLeaseSeconds uint   `json:"cron_lease_seconds,omitempty"`
+
+	Jobs JobsConfig `json:",omitempty"`
+
 	AppVariables map[string]string
 
 	SessionLifetimeHours uint `json:"session_lifetime_hours,omitempty"`
+	Session              SessionConfig
 }
 
 func (c *Config) Load(input string) (err error) {
@@ -120,16 +199,42 @@ func (c *Config) ReadFile(fPath string) error {
 		}
 	}
 
-	c.MappedAuth = make(map[string]*AuthGroup)
-	for _, aG := range c.AuthGroups {
+	c.MappedAuth = nil
+	c.MapAuthGroups()
+
+	return nil
+}
+
+// MapAuthGroups adds AuthGroups missing in MappedAuth (first group with given Name wins),
+// called by ReadFile and New, so Config built in code resolves groups too
+func (c *Config) MapAuthGroups() {
+	if c.MappedAuth == nil {
+		c.MappedAuth = make(map[string]*AuthGroup)
+	}
+
+	for ix, aG := range c.AuthGroups {
 		if len(aG.Name) > 0 {
-			_, notEmpty = c.MappedAuth[aG.Name]
+			_, notEmpty := c.MappedAuth[aG.Name]
 			if !notEmpty {
-				c.MappedAuth[aG.Name] = &aG
+				c.MappedAuth[aG.Name] = &c.AuthGroups[ix]
 			}
 		}
 	}
-	return nil
+}
+
+// AuthGroupNames returns names of AuthGroups mapped (by UserGroupName) from user groups
+func (c *Config) AuthGroupNames(userGroups []string) (names []string) {
+	for name, aG := range c.MappedAuth {
+		for _, userGroup := range userGroups {
+			if strings.EqualFold(aG.UserGroupName, userGroup) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return
 }
 
 func (c *Config) GetStoragePath(ins ...string) (string, error) {
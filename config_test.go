@@ -0,0 +1,80 @@
+package manago
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuthGroupNames(t *testing.T) {
+	conf := Config{AuthGroups: []AuthGroup{
+		{UserGroupName: "Domain Admins", Name: "admin"},
+		{UserGroupName: "Editors", Name: "editor"},
+		{UserGroupName: "Staff", Name: "editor"},
+		{UserGroupName: "Everyone", Name: ""},
+	}}
+	conf.MapAuthGroups()
+
+	tests := []struct {
+		name       string
+		userGroups []string
+		want       []string
+	}{
+		{"none", nil, nil},
+		{"case insensitive", []string{"domain admins"}, []string{"admin"}},
+		{"first group with name wins", []string{"Staff"}, nil},
+		{"several", []string{"Editors", "Domain Admins", "Other"}, []string{"admin", "editor"}},
+		{"unnamed group skipped", []string{"Everyone"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := conf.AuthGroupNames(tt.userGroups)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AuthGroupNames(%v) = %v, want %v", tt.userGroups, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapAuthGroupsKeepsExisting(t *testing.T) {
+	custom := &AuthGroup{UserGroupName: "Root", Name: "admin"}
+	conf := Config{
+		AuthGroups: []AuthGroup{{UserGroupName: "Domain Admins", Name: "admin"}, {UserGroupName: "Editors", Name: "editor"}},
+		MappedAuth: map[string]*AuthGroup{"admin": custom},
+	}
+	conf.MapAuthGroups()
+
+	if conf.MappedAuth["admin"] != custom {
+		t.Error("MapAuthGroups replaced group set in code")
+	}
+	if conf.MappedAuth["editor"] == nil {
+		t.Error("MapAuthGroups did not add missing group")
+	}
+}
+
+func TestAuthorizeGroups(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   Auth
+		groups string
+		want   bool
+	}{
+		{"not logged in", Auth{}, "", false},
+		{"no groups required", Auth{IsIn: true}, "", true},
+		{"member", Auth{IsIn: true, Groups: []string{"editor"}}, "admin, editor", true},
+		{"not member", Auth{IsIn: true, Groups: []string{"viewer"}}, "admin,editor", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctr := &Controller{Auth: tt.auth}
+			got := (&AuthorizeGroups{}).RunBefore(ctr, map[string]string{"groups": tt.groups})
+			if got != tt.want {
+				t.Errorf("RunBefore = %v, want %v", got, tt.want)
+			}
+			if !got && ctr.GetError().Code != 403 {
+				t.Errorf("error code = %d, want 403", ctr.GetError().Code)
+			}
+		})
+	}
+}
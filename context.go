@@ -0,0 +1,110 @@
+package manago
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Context is passed to HandlerFunc. It is a context.Context of the http request (cancelled when client disconnects)
+// and keeps whole request state: form and params, session, auth user, database session bound to request context,
+// view content and error. Embedded Controller gives access to all controller helpers.
+type Context struct {
+	context.Context
+	Controller
+
+	W http.ResponseWriter
+}
+
+func newContext(man *Manager, w http.ResponseWriter, r *http.Request, ctrName string) *Context {
+	ctx := &Context{
+		Context: r.Context(),
+		W:       w,
+	}
+	ctx.Name = ctrName
+	ctx.Man = man
+	ctx.Router = man.router
+
+	return ctx
+}
+
+// setupContextDB replaces database session with one bound to request context (see Db WithContext)
+func (ctx *Context) setupContextDB() error {
+	db, err := ctx.Man.Dbc.WithContext(ctx.Context)
+	if err != nil {
+		return fmt.Errorf("Context setup db failed: %w", err)
+	}
+
+	ctx.Db = db
+	return nil
+}
+
+// WithTimeout limits time of request context (so db queries and client calls), call returned cancel when done
+func (ctx *Context) WithTimeout(timeout time.Duration) (cancel context.CancelFunc) {
+	ctx.Context, cancel = context.WithTimeout(ctx.Context, timeout)
+
+	err := ctx.setupContextDB()
+	if err != nil {
+		ctx.SetError(500, err)
+	}
+
+	return
+}
+
+func (ctx *Context) Request() *http.Request {
+	return ctx.Req.R
+}
+
+func (ctx *Context) DB() *gorm.DB {
+	return ctx.Db
+}
+
+func (ctx *Context) Form(name string) string {
+	return ctx.Req.FormSingle(name)
+}
+
+func (ctx *Context) FormInt(name string) uint {
+	return ctx.Req.FormInt(name)
+}
+
+func (ctx *Context) Param(name string) string {
+	return ctx.Req.ParamByName(name)
+}
+
+func (ctx *Context) ParamInt(name string) int {
+	return ctx.Req.ParamIntByName(name)
+}
+
+func (ctx *Context) Params() httprouter.Params {
+	return ctx.Req.params
+}
+
+// User loads logged in user into model (see AuthGetUser)
+func (ctx *Context) User(model interface{}, preload ...string) error {
+	return ctx.AuthGetUser(model, preload...)
+}
+
+// Set puts value in view content
+func (ctx *Context) Set(name string, val interface{}) {
+	ctx.SetCt(name, val)
+}
+
+// Fail returns StatusError with code and message shown to user, to be returned from HandlerFunc
+func (ctx *Context) Fail(code int, msg string) error {
+	return NewStatusError(code, nil, msg)
+}
+
+// CallClient calls configured client with request context
+func (ctx *Context) CallClient(name string, path string, params url.Values, result interface{}) error {
+	client, present := ctx.Man.Clients[name]
+	if !present {
+		return fmt.Errorf("Context CallClient: client not found (%s)", name)
+	}
+
+	return client.CallContext(ctx.Context, path, params, result)
+}
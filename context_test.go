@@ -0,0 +1,36 @@
+package manago
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContextDB(t *testing.T) {
+	man := &Manager{Dbc: newTestDb(t, &dbTestItem{})}
+	r := httptest.NewRequest("GET", "/items", nil)
+	ctx := newContext(man, httptest.NewRecorder(), r, "items")
+
+	err := ctx.setupContextDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.DB().CommonDB() == nil {
+		t.Fatal("context session has no connection pool")
+	}
+	err = ctx.DB().Find(&[]dbTestItem{}).Error
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	cancel := ctx.WithTimeout(time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+
+	err = ctx.DB().Find(&[]dbTestItem{}).Error
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("query after timeout error = %v, want context.DeadlineExceeded", err)
+	}
+}
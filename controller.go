@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
-	"strconv"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
@@ -33,8 +32,11 @@ type Controlled interface {
 	SessionGet(string) string
 	IsError() bool
 	GetError() StatusError
+	GetValidationErrors() ValidationErrors
+	GetLayout() string
 	SetError(int, error, ...string)
 	ClearError()
+	GetAuth() Auth
 	SetManager(*Manager)
 	SetRouter(*httprouter.Router)
 	SetReqData(*http.Request, httprouter.Params)
@@ -65,6 +67,22 @@ type Auth struct {
 	IsIn     bool
 	Username string
 	Guid     string
+
+	// Groups are names of AuthGroups (from Config) which logged in user belongs to
+	Groups []string
+}
+
+// Can checks if user belongs to any of provided AuthGroup names, usable in templates: {{if .Auth.Can "admin"}}
+func (auth Auth) Can(groups ...string) bool {
+	for _, group := range groups {
+		for _, userGroup := range auth.Groups {
+			if group == userGroup {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 type StatusError struct {
@@ -137,6 +155,12 @@ func (ctr *Controller) Handle(options ...string) httprouter.Handle {
 	return ctr.Man.Handle(options...)
 }
 
+// Exclusive makes handle run one request at a time for lockName (see Manager Exclusive), eg.:
+// ctr.Router.POST("/invoice", ctr.Exclusive("invoice_number", ctr.Handle("Create", "./new", "/invoice/")))
+func (ctr *Controller) Exclusive(lockName string, handle httprouter.Handle) httprouter.Handle {
+	return ctr.Man.Exclusive(lockName, handle)
+}
+
 func (ctr *Controller) GetMiddleware(middleware Middleware, params ...string) *MidMethodSet {
 	return ctr.Man.Mid.GetSet(middleware, params...)
 }
@@ -157,6 +181,10 @@ func (ctr *Controller) SetMiddlewareDirect(mid Middleware, methods ...string) er
 // 	return Fire(options...)
 // }
 
+func (ctr *Controller) GetAuth() Auth {
+	return ctr.Auth
+}
+
 func (ctr *Controller) SetManager(man *Manager) {
 	ctr.Man = man
 }
@@ -178,7 +206,7 @@ func (ctr *Controller) SetRouter(r *httprouter.Router) {
 
 func (ctr *Controller) StartSession(s *scs.SessionManager, w http.ResponseWriter, r *http.Request) error {
 
-	auth := s.GetString(r.Context(), "auth")
+	auth := s.GetString(r.Context(), sessionKeyAuth)
 	if len(auth) > 0 {
 		ctr.Auth.IsIn = true
 		ctr.Auth.Guid = auth
@@ -186,7 +214,11 @@ func (ctr *Controller) StartSession(s *scs.SessionManager, w http.ResponseWriter
 		ctr.Auth = Auth{}
 	}
 
-	ctr.Auth.Username = s.GetString(r.Context(), "username")
+	ctr.Auth.Username = s.GetString(r.Context(), sessionKeyUsername)
+	if ctr.Auth.IsIn {
+		userGroups, _ := s.Get(r.Context(), sessionKeyUserGroups).([]string)
+		ctr.Auth.Groups = ctr.Man.Config.AuthGroupNames(userGroups)
+	}
 
 	ctr.Req.SetCtQuick(ctr.Auth)
 	ctr.Req.SetCt("AppVersion", ctr.Man.AppVersion)
@@ -194,6 +226,66 @@ func (ctr *Controller) StartSession(s *scs.SessionManager, w http.ResponseWriter
 	return nil
 }
 
+// Login authenticates user with manager Authenticator and logs the user in (see LoginUser)
+func (ctr *Controller) Login(username string, password string, remember bool) error {
+	user, err := ctr.Man.Authenticator.Authenticate(ctr.Db, username, password)
+	if err != nil {
+		return err
+	}
+
+	return ctr.LoginUser(user, remember)
+}
+
+// LoginUser renews session token (preventing session fixation) and stores user in session
+func (ctr *Controller) LoginUser(user AuthUser, remember bool) error {
+	ctx := ctr.Req.R.Context()
+
+	err := ctr.Man.sessionManager.RenewToken(ctx)
+	if err != nil {
+		return fmt.Errorf("Controller LoginUser: renewing session token failed: %w", err)
+	}
+
+	ctr.Man.sessionManager.Put(ctx, sessionKeyAuth, user.GetAuthId())
+	ctr.Man.sessionManager.Put(ctx, sessionKeyUsername, user.GetUsername())
+	if ctr.Man.Config.Auth.RememberMe {
+		ctr.Man.sessionManager.RememberMe(ctx, remember)
+	}
+
+	userGroups := []string{}
+	grouped, ok := user.(GroupedUser)
+	if ok {
+		userGroups = grouped.GetUserGroups()
+	}
+	ctr.Man.sessionManager.Put(ctx, sessionKeyUserGroups, userGroups)
+
+	ctr.Auth = Auth{
+		IsIn:     true,
+		Guid:     user.GetAuthId(),
+		Username: user.GetUsername(),
+		Groups:   ctr.Man.Config.AuthGroupNames(userGroups),
+	}
+	ctr.Req.SetCtQuick(ctr.Auth)
+
+	return nil
+}
+
+// Logout destroys whole session data
+func (ctr *Controller) Logout() error {
+	err := ctr.Man.sessionManager.Destroy(ctr.Req.R.Context())
+	if err != nil {
+		return fmt.Errorf("Controller Logout: destroying session failed: %w", err)
+	}
+
+	ctr.Auth = Auth{}
+	ctr.Req.SetCtQuick(ctr.Auth)
+
+	return nil
+}
+
+func (ctr *Controller) HashPassword(password string) (string, error) {
+	return ctr.Man.Authenticator.HashPassword(password)
+}
+
 func (ctr *Controller) SessionRelease(w http.ResponseWriter) {
 
 }
@@ -210,6 +302,60 @@ func (ctr *Controller) SessionGet(key string) string {
 	return ctr.Man.sessionManager.GetString(ctr.Req.R.Context(), key)
 }
 
+func (ctr *Controller) SessionSetInt(key string, value int) {
+	ctr.Man.sessionManager.Put(ctr.Req.R.Context(), key, value)
+}
+
+// SessionGetInt returns 0 when key not exists or holds other type
+func (ctr *Controller) SessionGetInt(key string) int {
+	return ctr.Man.sessionManager.GetInt(ctr.Req.R.Context(), key)
+}
+
+func (ctr *Controller) SessionSetBool(key string, value bool) {
+	ctr.Man.sessionManager.Put(ctr.Req.R.Context(), key, value)
+}
+
+func (ctr *Controller) SessionGetBool(key string) bool {
+	return ctr.Man.sessionManager.GetBool(ctr.Req.R.Context(), key)
+}
+
+// SessionSetTime stores time as RFC3339 string (time.Time is not registered in session gob codec)
+func (ctr *Controller) SessionSetTime(key string, value time.Time) {
+	ctr.Man.sessionManager.Put(ctr.Req.R.Context(), key, value.Format(time.RFC3339Nano))
+}
+
+// SessionGetTime returns zero time when key not exists
+func (ctr *Controller) SessionGetTime(key string) time.Time {
+	value, _ := time.Parse(time.RFC3339Nano, ctr.Man.sessionManager.GetString(ctr.Req.R.Context(), key))
+	return value
+}
+
+// SessionSetJson stores value encoded as json
+func (ctr *Controller) SessionSetJson(key string, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("SessionSetJson %s: %w", key, err)
+	}
+
+	ctr.Man.sessionManager.Put(ctr.Req.R.Context(), key, string(encoded))
+	return nil
+}
+
+// SessionGetJson decodes value stored by SessionSetJson into target, reports false when key not exists
+func (ctr *Controller) SessionGetJson(key string, target interface{}) (bool, error) {
+	encoded := ctr.Man.sessionManager.GetString(ctr.Req.R.Context(), key)
+	if len(encoded) == 0 {
+		return false, nil
+	}
+
+	err := json.Unmarshal([]byte(encoded), target)
+	if err != nil {
+		return false, fmt.Errorf("SessionGetJson %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
 func (ctr *Controller) IsError() bool {
 	if ctr.E.Code > 399 {
 		return true
@@ -246,63 +392,53 @@ func (ctr *Controller) ClearError() {
 }
 
 func (ctr *Controller) FillModel(model interface{}) int {
+	valuesCount, _ := ctr.fillModel(model)
+	return valuesCount
+}
 
-	valuesCount := 0
-	v := reflect.ValueOf(model).Elem()
-
-	for ix := 0; ix < v.NumField(); ix++ {
-		fieldName := v.Type().Field(ix).Name
-		vals, exist := ctr.Req.R.Form[strcase.ToSnake(fieldName)]
-
-		if v.Field(ix).CanSet() && exist && len(vals) > 0 && fieldName != "ID" {
-			formVal := vals[0]
-			switch v.Field(ix).Interface().(type) {
-
-			case string:
-				if len(formVal) > 0 {
-					v.Field(ix).SetString(formVal)
-					valuesCount++
-				}
-
-			case int:
-				iVal, iErr := strconv.ParseInt(formVal, 10, 64)
-				if iVal > 0 && iErr == nil {
-					v.Field(ix).SetInt(iVal)
-					valuesCount++
-				}
-			case uint:
-				iVal, iErr := strconv.ParseUint(formVal, 10, 64)
-				if iVal > 0 && iErr == nil {
-					v.Field(ix).SetUint(iVal)
-					valuesCount++
-				}
-			case bool:
-				bVal, err := strconv.ParseBool(formVal)
-				if err == nil {
-					v.Field(ix).SetBool(bVal)
-					valuesCount++
-				} else {
-					if formVal == "on" {
-						v.Field(ix).SetBool(true)
-						valuesCount++
-					}
-				}
-
-			case time.Time:
-				time, err := time.Parse("2006-01-02", formVal)
-				if err == nil {
-					v.Field(ix).Set(reflect.ValueOf(time))
-					valuesCount++
-				}
-			default:
+// FillAndValidate fills model from form (like FillModel) and validates it with Validate. Errors, also for values
+// which failed to parse, are put in view content as ValidationErrors and redirect is cancelled, so the form
+// can be rendered again. HandleJson routes respond with 422 and errors in json body.
+func (ctr *Controller) FillAndValidate(model interface{}) (filled int, errs ValidationErrors) {
+	filled, errs = ctr.fillModel(model)
 
-			}
-		}
+	for field, msg := range Validate(model) {
+		errs.Add(field, msg)
 	}
 
+	if len(errs) > 0 {
+		ctr.SetValidationErrors(errs)
+	}
+
+	return
+}
+
+// SetValidationErrors attaches field errors to response (see FillAndValidate)
+func (ctr *Controller) SetValidationErrors(errs ValidationErrors) {
+	ctr.Req.validationErrors = errs
+	ctr.Req.ClearRedir()
+	ctr.SetCt("ValidationErrors", errs)
+}
+
+// SetLayout selects layout for rendered template (eg. "print.gohtml" or NoLayout), overriding template front-matter
+func (ctr *Controller) SetLayout(layout string) {
+	ctr.Req.layout = layout
+}
+
+func (ctr *Controller) GetLayout() string {
+	return ctr.Req.layout
+}
+
+func (ctr *Controller) GetValidationErrors() ValidationErrors {
+	return ctr.Req.validationErrors
+}
+
+func (ctr *Controller) fillModel(model interface{}) (valuesCount int, errs ValidationErrors) {
+	valuesCount, errs = FillForm(model, ctr.Req.R.Form)
+
 	log.Printf("FillModel for %T, values filled: %d", model, valuesCount)
 
-	return valuesCount
+	return
 }
 
 func (ctr *Controller) GetModel(model interface{}, preload ...string) (err error) {
@@ -530,6 +666,32 @@ func (ctr *Controller) SendMessage(msg Message) error {
 	return ctr.Man.Messaging.Send(msg)
 }
 
+// NextNumber allocates next number from sequence registered in manager (see Sequence)
+func (ctr *Controller) NextNumber(name string) (string, error) {
+	seq, err := ctr.Man.sequence(name)
+	if err != nil {
+		return "", err
+	}
+
+	return seq.Next(ctr.Db, time.Now())
+}
+
+// NextNumberTx allocates next number inside transaction tx, so it is released when tx is rolled back
+func (ctr *Controller) NextNumberTx(tx *gorm.DB, name string) (string, error) {
+	seq, err := ctr.Man.sequence(name)
+	if err != nil {
+		return "", err
+	}
+
+	number, _, err := seq.NextTx(tx, time.Now())
+	return number, err
+}
+
+// Enqueue adds job for handler in "controller.Method" form (method with signature func(*Job) error), see Manager Enqueue
+func (ctr *Controller) Enqueue(handler string, payload interface{}, opts ...JobOptions) (*Job, error) {
+	return ctr.Man.Enqueue(ctr.Db, handler, payload, opts...)
+}
+
 func (ctr *Controller) SetRequestStartTime(when *time.Time) {
 	ctr.Req.startTime = when
 }
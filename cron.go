@@ -1,12 +1,44 @@
 package manago
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 )
 
+// OverlapPolicy decides what happens when task is due while its previous run is still running
+type OverlapPolicy string
+
+const (
+	// OverlapSkip records skipped run (default)
+	OverlapSkip OverlapPolicy = "skip"
+	// OverlapQueue runs task once more right after current run finishes
+	OverlapQueue OverlapPolicy = "queue"
+	// OverlapAllow starts another run in parallel, with db cron locks (one lease per task) it works like OverlapSkip
+	OverlapAllow OverlapPolicy = "allow"
+)
+
+// CronOutcome is result of single task run
+type CronOutcome string
+
+const (
+	CronOk      CronOutcome = "ok"
+	CronError   CronOutcome = "error"
+	CronPanic   CronOutcome = "panic"
+	CronTimeout CronOutcome = "timeout"
+	CronSkipped CronOutcome = "skipped"
+)
+
+const cronHistorySize = 20
+
+// cronStateLock guards creating state of tasks built without NewCron or NewCronExpr
+var cronStateLock sync.Mutex
+
 type Cron struct {
 	Minute  *int
 	Hour    *int
@@ -15,13 +47,55 @@ type Cron struct {
 	ControllerName string
 	MethodName     string
 
-	lastRun time.Time
+	// Schedule (eg. from ParseSchedule) is used instead of Minute, Hour and Weekday when set,
+	// it is calculated in Location (local time when nil)
+	Schedule Schedule
+	Location *time.Location
+
+	// Overlap policy, skip when empty; Timeout (when set) reports run as timed out, method is not interrupted
+	Overlap OverlapPolicy
+	Timeout time.Duration
+
+	// Disabled task is listed in CronStatus but never runs
+	Disabled bool
+
+	state *cronState
+}
+
+// CronRun is record of single task run, Stack is set for panics
+type CronRun struct {
+	Started  time.Time   `json:"started"`
+	Finished time.Time   `json:"finished"`
+	Outcome  CronOutcome `json:"outcome"`
+	Error    string      `json:"error,omitempty"`
+	Stack    string      `json:"stack,omitempty"`
+}
+
+// CronTaskStatus describes task with its recent runs (newest first)
+type CronTaskStatus struct {
+	Controller string    `json:"controller"`
+	Method     string    `json:"method"`
+	Disabled   bool      `json:"disabled"`
+	Running    int       `json:"running"`
+	LastRun    *CronRun  `json:"last_run,omitempty"`
+	NextRun    time.Time `json:"next_run"`
+	History    []CronRun `json:"history"`
+}
+
+type cronState struct {
+	lock     sync.Mutex
+	lastRun  time.Time
+	nextRun  time.Time
+	running  int
+	queued   bool
+	queuedAt time.Time
+	history  []CronRun
 }
 
 func NewCron(ctr string, mtd string, when ...int) (task Cron) {
 
 	if len(ctr) > 0 && len(mtd) > 0 {
-		task = Cron{ControllerName: ctr, MethodName: mtd}
+		task = Cron{ControllerName: ctr, MethodName: mtd, state: &cronState{}}
 	} else {
 		task = Cron{}
 		return
@@ -48,53 +122,440 @@ func NewCron(ctr string, mtd string, when ...int) (task Cron) {
 	return
 }
 
-func (cr *Cron) CheckTime() bool {
-	now := time.Now()
-	if !cr.lastRun.IsZero() {
-		if time.Since(cr.lastRun) < time.Minute {
-			return false
+// NewCronExpr prepares task with schedule expression (see ParseSchedule), optionally in provided location
+func NewCronExpr(ctr string, mtd string, expr string, location ...*time.Location) (task Cron, err error) {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		err = fmt.Errorf("NewCronExpr %s->%s: %w", ctr, mtd, err)
+		return
+	}
+
+	task = Cron{ControllerName: ctr, MethodName: mtd, Schedule: schedule, state: &cronState{}}
+	if len(location) > 0 {
+		task.Location = location[0]
+	}
+
+	return
+}
+
+// cronFromConfig builds task from config checking controller, method, schedule, timezone and overlap policy
+func (man *Manager) cronFromConfig(cc CronConfig) (task Cron, err error) {
+	typ, isOk := man.controllersReflected[cc.Controller]
+	if !isOk {
+		err = fmt.Errorf("cron config: controller [%s] not found", cc.Controller)
+		return
+	}
+
+	if !reflect.New(typ).MethodByName(cc.Method).IsValid() {
+		err = fmt.Errorf("cron config: method [%s] not found in controller [%s]", cc.Method, cc.Controller)
+		return
+	}
+
+	var location *time.Location
+	if len(cc.Timezone) > 0 {
+		location, err = time.LoadLocation(cc.Timezone)
+		if err != nil {
+			err = fmt.Errorf("cron config %s.%s: wrong timezone: %w", cc.Controller, cc.Method, err)
+			return
 		}
 	}
 
-	if cr.Weekday != nil && *cr.Weekday != int(now.Weekday()) {
-		return false
+	task, err = NewCronExpr(cc.Controller, cc.Method, cc.Schedule)
+	if err != nil {
+		err = fmt.Errorf("cron config: %w", err)
+		return
 	}
+	task.Location = location
 
-	if cr.Hour != nil && *cr.Hour != now.Hour() {
+	switch OverlapPolicy(strings.ToLower(cc.Overlap)) {
+	case "", OverlapSkip:
+	case OverlapQueue:
+		task.Overlap = OverlapQueue
+	case OverlapAllow:
+		if strings.EqualFold(man.Config.CronLock, "db") {
+			err = fmt.Errorf("cron config %s.%s: overlap policy allow can not be used with db cron locks", cc.Controller, cc.Method)
+			return
+		}
+		task.Overlap = OverlapAllow
+	default:
+		err = fmt.Errorf("cron config %s.%s: unknown overlap policy [%s]", cc.Controller, cc.Method, cc.Overlap)
+		return
+	}
+
+	task.Timeout = time.Duration(cc.TimeoutSeconds) * time.Second
+	task.Disabled = cc.Enabled != nil && !*cc.Enabled
+
+	return
+}
+
+func (cr *Cron) name() string {
+	return cr.ControllerName + "." + cr.MethodName
+}
+
+func (cr *Cron) st() *cronState {
+	cronStateLock.Lock()
+	defer cronStateLock.Unlock()
+
+	if cr.state == nil {
+		cr.state = &cronState{}
+	}
+
+	return cr.state
+}
+
+// schedule returns Schedule or one built from Minute, Hour and Weekday fields (nil means every value)
+func (cr *Cron) schedule() Schedule {
+	if cr.Schedule != nil {
+		return cr.Schedule
+	}
+
+	spec := &SpecSchedule{Second: 1, Minute: 1<<60 - 1, Hour: 1<<24 - 1, Dom: 1<<32 - 2, Month: 1<<13 - 2, Dow: 1<<7 - 1, domAny: true, dowAny: true}
+	if cr.Minute != nil {
+		spec.Minute = 1 << uint(*cr.Minute)
+	}
+	if cr.Hour != nil {
+		spec.Hour = 1 << uint(*cr.Hour)
+	}
+	if cr.Weekday != nil {
+		spec.Dow = 1 << uint(*cr.Weekday)
+		spec.dowAny = false
+	}
+	cr.Schedule = spec
+
+	return spec
+}
+
+// Next returns next run time after from
+func (cr *Cron) Next(from time.Time) time.Time {
+	if cr.Location != nil {
+		from = from.In(cr.Location)
+	} else {
+		from = from.In(time.Local)
+	}
+
+	return cr.schedule().Next(from)
+}
+
+// NextRun returns scheduled time of next run (zero before task was checked first time)
+func (cr *Cron) NextRun() time.Time {
+	state := cr.st()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	return state.nextRun
+}
+
+// CheckTime reports if task is due, first call only schedules next run
+func (cr *Cron) CheckTime() bool {
+	if cr.Disabled {
 		return false
 	}
 
-	if cr.Minute != nil && *cr.Minute != now.Minute() {
+	now := time.Now()
+	state := cr.st()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	if state.nextRun.IsZero() {
+		state.nextRun = cr.Next(now)
 		return false
 	}
 
-	return true
+	return !now.Before(state.nextRun)
+}
+
+// Status returns task description with run history
+func (cr *Cron) Status() CronTaskStatus {
+	state := cr.st()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	status := CronTaskStatus{
+		Controller: cr.ControllerName,
+		Method:     cr.MethodName,
+		Disabled:   cr.Disabled,
+		Running:    state.running,
+		NextRun:    state.nextRun,
+		History:    make([]CronRun, len(state.history)),
+	}
+	for ix := range state.history {
+		status.History[ix] = state.history[len(state.history)-1-ix]
+	}
+	if len(status.History) > 0 {
+		status.LastRun = &status.History[0]
+	}
+
+	return status
 }
 
+func (cr *Cron) record(run CronRun) {
+	state := cr.st()
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	state.history = append(state.history, run)
+	if len(state.history) > cronHistorySize {
+		state.history = state.history[len(state.history)-cronHistorySize:]
+	}
+}
+
+// RunMethod starts task method in background (tracked by Manager Shutdown) according to Overlap policy,
+// returned error means task could not be started, it is also recorded and reported
 func (cr *Cron) RunMethod(man *Manager) error {
-	cr.lastRun = time.Now()
+	now := time.Now()
+	state := cr.st()
+
+	state.lock.Lock()
+	scheduledAt := state.nextRun
+	if scheduledAt.IsZero() {
+		scheduledAt = now
+	}
+	state.lastRun = now
+	state.nextRun = cr.Next(now)
+	if state.running > 0 {
+		overlap := cr.Overlap
+		if overlap == OverlapAllow && man.cronLocks != nil {
+			// task has one lease, parallel run could not claim it
+			overlap = OverlapSkip
+		}
+		switch overlap {
+		case OverlapAllow:
+		case OverlapQueue:
+			state.queued = true
+			state.queuedAt = scheduledAt
+			state.lock.Unlock()
+			log.Printf("##Cron RunMethod: %s still running, run queued.\n", cr.name())
+			return nil
+		default:
+			state.lock.Unlock()
+			log.Printf("##Cron RunMethod: %s still running, run skipped.\n", cr.name())
+			cr.record(CronRun{Started: now, Finished: now, Outcome: CronSkipped})
+			return nil
+		}
+	}
+	state.running++
+	state.lock.Unlock()
+
+	method, ctr, err := cr.prepare(man)
+	var release func()
+	if err == nil {
+		release, err = cr.claim(man, scheduledAt)
+	}
+	if err != nil {
+		cr.recordFailure(man, err)
+	}
+	if err != nil || release == nil {
+		state.lock.Lock()
+		state.running--
+		state.lock.Unlock()
+		return err
+	}
+
+	log.Printf("##Cron RunMethod passed, running %s from controller %s.\n", cr.MethodName, cr.ControllerName)
+	man.goTracked(func() {
+		cr.runLoop(man, method, ctr, release)
+	})
+	return nil
+}
+
+// recordFailure records and reports run which could not be started
+func (cr *Cron) recordFailure(man *Manager, err error) {
+	now := time.Now()
+	run := CronRun{Started: now, Finished: now, Outcome: CronError, Error: err.Error()}
+	cr.record(run)
+	man.reportCronFailure(cr, run)
+}
+
+// claim returns release func when run scheduled at scheduledAt is ours (always without cron locks),
+// nil when other instance claimed it
+func (cr *Cron) claim(man *Manager, scheduledAt time.Time) (release func(), err error) {
+	if man.cronLocks == nil {
+		return func() {}, nil
+	}
+
+	claimed, err := man.cronLocks.claim(cr.name(), scheduledAt)
+	if err != nil || !claimed {
+		if err == nil {
+			log.Printf("##Cron %s run scheduled at %v claimed by other instance.\n", cr.name(), scheduledAt)
+		}
+		return nil, err
+	}
+
+	return man.cronLocks.hold(cr.name()), nil
+}
+
+// runLoop runs method and then queued runs, every queued run gets fresh controller
+func (cr *Cron) runLoop(man *Manager, method reflect.Value, ctr Controlled, release func()) {
+	state := cr.st()
+
+	for {
+		cr.execute(man, method, ctr)
+		release()
+
+		state.lock.Lock()
+		if !state.queued {
+			state.running--
+			state.lock.Unlock()
+			return
+		}
+		state.queued = false
+		scheduledAt := state.queuedAt
+		state.lock.Unlock()
+
+		var err error
+		method, ctr, err = cr.prepare(man)
+		if err == nil {
+			release, err = cr.claim(man, scheduledAt)
+		}
+		if err != nil {
+			cr.recordFailure(man, err)
+		}
+		if err != nil || release == nil {
+			state.lock.Lock()
+			state.running--
+			state.lock.Unlock()
+			return
+		}
+	}
+}
+
+// execute calls method recording the run, when Timeout passes timeout run is recorded and reported
+// and execute keeps waiting for method to finish, final outcome is recorded too (and reported unless ok)
+func (cr *Cron) execute(man *Manager, method reflect.Value, ctr Controlled) {
+	started := time.Now()
+	done := make(chan CronRun, 1)
+
+	go func() {
+		run := CronRun{Started: started, Outcome: CronOk}
+		err, stack := safeCall(method)
+		run.Finished = time.Now()
+
+		switch {
+		case len(stack) > 0:
+			run.Outcome, run.Error, run.Stack = CronPanic, err.Error(), string(stack)
+		case err != nil:
+			run.Outcome, run.Error = CronError, err.Error()
+		case ctr.IsError():
+			run.Outcome = CronError
+			run.Error = ctr.GetError().Msg
+			if ctr.GetError().Err != nil {
+				run.Error = ctr.GetError().Err.Error()
+			}
+		}
+
+		done <- run
+	}()
+
+	var timeout <-chan time.Time
+	if cr.Timeout > 0 {
+		timer := time.NewTimer(cr.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case run := <-done:
+		cr.record(run)
+		if run.Outcome != CronOk {
+			man.reportCronFailure(cr, run)
+		}
+	case <-timeout:
+		run := CronRun{Started: started, Finished: time.Now(), Outcome: CronTimeout, Error: fmt.Sprintf("still running after %v", cr.Timeout)}
+		cr.record(run)
+		man.reportCronFailure(cr, run)
+
+		run = <-done
+		log.Printf("##Cron %s finished %v after timeout with outcome %s %s\n", cr.name(), run.Finished.Sub(started), run.Outcome, run.Error)
+		cr.record(run)
+		if run.Outcome != CronOk {
+			man.reportCronFailure(cr, run)
+		}
+	}
+}
 
-	typ, isOk := man.controllersReflected[cr.ControllerName]
+// prepare returns task method bound to new controller with db set up
+func (cr *Cron) prepare(man *Manager) (method reflect.Value, ctr Controlled, err error) {
+	method, ctr, err = man.controllerMethod(cr.ControllerName, cr.MethodName)
+	if err != nil {
+		err = fmt.Errorf("Cron checkMethod: %w", err)
+	}
+
+	return
+}
+
+// controllerMethod returns method of new controller (with manager, empty request and db set up), used by cron and jobs
+func (man *Manager) controllerMethod(ctrName string, mtdName string) (method reflect.Value, ctr Controlled, err error) {
+	typ, isOk := man.controllersReflected[ctrName]
 	if !isOk {
-		return fmt.Errorf("Cron checkMethod: controller [%s] not found!", cr.ControllerName)
+		err = fmt.Errorf("controller [%s] not found!", ctrName)
+		return
 	}
 
-	if !reflect.New(typ).MethodByName(cr.MethodName).IsValid() {
-		return fmt.Errorf("Cron checkMethod: method[%v] not found!", cr.MethodName)
+	if !reflect.New(typ).MethodByName(mtdName).IsValid() {
+		err = fmt.Errorf("method[%v] not found!", mtdName)
+		return
 	}
 
-	ctr := reflect.New(typ).Interface().(Controlled)
+	ctr = reflect.New(typ).Interface().(Controlled)
 
-	method := reflect.ValueOf(ctr).MethodByName(cr.MethodName)
+	method = reflect.ValueOf(ctr).MethodByName(mtdName)
 	ctr.SetManager(man)
 	ctr.SetEmptyReq()
-	_, err := ctr.SetupDB(man.Dbc)
+	_, err = ctr.SetupDB(man.Dbc)
 
 	if err != nil {
-		return fmt.Errorf("Cron checkMethod failed to setup db: \n%v\n", err.Error())
+		err = fmt.Errorf("failed to setup db: \n%v\n", err.Error())
 	}
 
-	log.Printf("##Cron RunMethod passed, running %s from controller %s.\n", cr.MethodName, cr.ControllerName)
-	go method.Call([]reflect.Value{})
+	return
+}
+
+// safeCall calls fn recovering panics (stack is returned then), non nil error returned by fn is passed as err
+func safeCall(fn reflect.Value, args ...reflect.Value) (err error, stack []byte) {
+	defer func() {
+		rec := recover()
+		if rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+			stack = debug.Stack()
+		}
+	}()
+
+	for _, result := range fn.Call(args) {
+		resErr, isErr := result.Interface().(error)
+		if isErr && resErr != nil {
+			err = resErr
+		}
+	}
+
+	return
+}
+
+func (man *Manager) reportCronFailure(cr *Cron, run CronRun) {
+	log.Printf("##Cron %s failed (%s): %s\n%s", cr.name(), run.Outcome, run.Error, run.Stack)
+
+	man.Logger.LogError("cron/"+cr.name(), "cron", errors.New(run.Error), 500)
+
+	if man.Messaging != nil {
+		err := man.Messaging.QuickSend(fmt.Sprintf("%s: cron task %s failed (%s) at %s: %s", man.Config.Name, cr.name(), run.Outcome, run.Started.Format(time.RFC3339), run.Error))
+		if err != nil {
+			log.Printf("Manager reportCronFailure: sending message failed: %v", err)
+		}
+	}
+}
+
+// CronStatus returns status of all cron tasks
+func (man *Manager) CronStatus() []CronTaskStatus {
+	statuses := make([]CronTaskStatus, len(man.CronTasks))
+	for ix := range man.CronTasks {
+		statuses[ix] = man.CronTasks[ix].Status()
+	}
+
+	return statuses
+}
+
+// CronStatusFunc lists cron tasks with last and next run, to be served with HandleFuncJson, eg.:
+// man.Route("GET", "/admin/cron", man.HandleFuncJson("admin.Cron", man.CronStatusFunc))
+func (man *Manager) CronStatusFunc(ctx *Context) error {
+	ctx.Set("tasks", man.CronStatus())
 	return nil
 }
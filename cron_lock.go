@@ -0,0 +1,163 @@
+package manago
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+const defaultCronLease = time.Minute
+
+var errCronLeaseLost = errors.New("lease lost, task can be run by other instance")
+
+// CronLock is lease of cron task run shared by instances using the same database, table is created by Manager Migrate
+type CronLock struct {
+	ID          uint
+	Name        string `gorm:"unique_index:idx_manago_cron_lock_name;not null"`
+	ScheduledAt time.Time
+	Owner       string
+	LeaseUntil  time.Time
+}
+
+func (CronLock) TableName() string {
+	return "manago_cron_locks"
+}
+
+// cronLocker claims scheduled runs in manago_cron_locks, every scheduled time is claimed once
+// and no one else claims task while lease is valid (it is renewed during run and released after)
+type cronLocker struct {
+	dbc   *Db
+	owner string
+	lease time.Duration
+}
+
+func newCronLocker(dbc *Db, lease time.Duration) *cronLocker {
+	if lease <= 0 {
+		lease = defaultCronLease
+	}
+
+	return &cronLocker{
+		dbc:   dbc,
+		owner: instanceName(),
+		lease: lease,
+	}
+}
+
+// instanceName identifies running app instance (host/pid/random) in cron locks and jobs
+func instanceName() string {
+	host, _ := os.Hostname()
+	random := make([]byte, 4)
+	rand.Read(random)
+
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(random))
+}
+
+// claim reports if this instance got run of task scheduled at scheduledAt
+func (cl *cronLocker) claim(name string, scheduledAt time.Time) (bool, error) {
+	db, err := cl.dbc.Open()
+	if err != nil {
+		return false, fmt.Errorf("cronLocker claim %s: %w", name, err)
+	}
+	now := time.Now().UTC()
+	scheduledAt = scheduledAt.UTC()
+
+	res := db.Model(&CronLock{}).
+		Where("name = ? AND scheduled_at < ? AND lease_until < ?", name, scheduledAt, now).
+		Updates(map[string]interface{}{"scheduled_at": scheduledAt, "owner": cl.owner, "lease_until": now.Add(cl.lease)})
+	if res.Error != nil {
+		return false, fmt.Errorf("cronLocker claim %s: %w", name, res.Error)
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+
+	count := 0
+	err = db.Model(&CronLock{}).Where("name = ?", name).Count(&count).Error
+	if err != nil || count > 0 {
+		return false, err
+	}
+
+	err = db.Create(&CronLock{Name: name, ScheduledAt: scheduledAt, Owner: cl.owner, LeaseUntil: now.Add(cl.lease)}).Error
+	if err != nil {
+		// other instance inserted the row first
+		db.Model(&CronLock{}).Where("name = ?", name).Count(&count)
+		if count > 0 {
+			return false, nil
+		}
+		return false, fmt.Errorf("cronLocker claim %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// hold renews lease until returned release func is called (see renewLease), release ends lease
+func (cl *cronLocker) hold(name string) (release func()) {
+	stop := renewLease("cronLocker hold "+name, cl.lease, func() error {
+		return cl.setLease(name, time.Now().Add(cl.lease))
+	}, errCronLeaseLost)
+
+	return func() {
+		stop()
+
+		err := cl.setLease(name, time.Now())
+		if err != nil {
+			log.Printf("cronLocker release %s: %v", name, err)
+		}
+	}
+}
+
+// renewLease calls renew every third of lease until returned stop func is called,
+// renewing stops when renew returns lost error (lease was taken over by other instance or worker)
+func renewLease(name string, lease time.Duration, renew func() error, lost error) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := renew()
+				if errors.Is(err, lost) {
+					log.Printf("%s: %v", name, err)
+					return
+				}
+				if err != nil {
+					log.Printf("%s: renewing lease failed: %v", name, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// setLease moves end of lease owned by this instance, errCronLeaseLost is returned when other instance owns it
+func (cl *cronLocker) setLease(name string, until time.Time) error {
+	db, err := cl.dbc.Open()
+	if err != nil {
+		return err
+	}
+
+	res := db.Model(&CronLock{}).Where("name = ? AND owner = ?", name, cl.owner).Update("lease_until", until.UTC())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errCronLeaseLost
+	}
+
+	return nil
+}
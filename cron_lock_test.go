@@ -0,0 +1,150 @@
+package manago
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCronLockerClaim(t *testing.T) {
+	dbc := newTestDb(t)
+	first, second := newCronLocker(dbc, time.Minute), newCronLocker(dbc, time.Minute)
+	at := time.Date(2024, 3, 7, 10, 15, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		locker  *cronLocker
+		at      time.Time
+		release bool
+		want    bool
+	}{
+		{"first claim", first, at, false, true},
+		{"same time claimed", second, at, false, false},
+		{"next time while lease valid", second, at.Add(15 * time.Minute), true, false},
+		{"next time after release", second, at.Add(15 * time.Minute), false, true},
+		{"older time", first, at, false, false},
+	}
+
+	for _, tt := range tests {
+		claimed, err := tt.locker.claim("task", tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed != tt.want {
+			t.Errorf("%s: claimed = %v, want %v", tt.name, claimed, tt.want)
+		}
+		if tt.release {
+			err = first.setLease("task", time.Now().Add(-time.Second))
+			if err != nil {
+				t.Fatalf("%s: releasing lease failed: %v", tt.name, err)
+			}
+		}
+	}
+
+	other, err := first.claim("other", at)
+	if err != nil || !other {
+		t.Errorf("claim of other task = %v (%v), want true", other, err)
+	}
+}
+
+func TestCronLockerLeaseLost(t *testing.T) {
+	dbc := newTestDb(t)
+	first, second := newCronLocker(dbc, time.Minute), newCronLocker(dbc, time.Minute)
+	at := time.Date(2024, 3, 7, 10, 15, 0, 0, time.UTC)
+
+	claimed, err := first.claim("task", at)
+	if err != nil || !claimed {
+		t.Fatalf("first claim = %v (%v)", claimed, err)
+	}
+	err = first.setLease("task", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimed, err = second.claim("task", at.Add(time.Minute))
+	if err != nil || !claimed {
+		t.Fatalf("second claim = %v (%v)", claimed, err)
+	}
+
+	err = first.setLease("task", time.Now().Add(time.Minute))
+	if !errors.Is(err, errCronLeaseLost) {
+		t.Errorf("setLease after lease was taken = %v, want errCronLeaseLost", err)
+	}
+}
+
+func TestCronLockerHoldRenews(t *testing.T) {
+	dbc := newTestDb(t)
+	first, second := newCronLocker(dbc, 150*time.Millisecond), newCronLocker(dbc, 150*time.Millisecond)
+	at := time.Date(2024, 3, 7, 10, 15, 0, 0, time.UTC)
+
+	claimed, err := first.claim("task", at)
+	if err != nil || !claimed {
+		t.Fatalf("first claim = %v (%v)", claimed, err)
+	}
+	release := first.hold("task")
+
+	time.Sleep(400 * time.Millisecond)
+	claimed, err = second.claim("task", at.Add(time.Minute))
+	if err != nil || claimed {
+		t.Errorf("claim while lease is held = %v (%v), want false", claimed, err)
+	}
+
+	release()
+	claimed, err = second.claim("task", at.Add(time.Minute))
+	if err != nil || !claimed {
+		t.Errorf("claim after release = %v (%v), want true", claimed, err)
+	}
+}
+
+func TestRenewLease(t *testing.T) {
+	lost := errors.New("lost")
+	other := errors.New("other")
+
+	tests := []struct {
+		name    string
+		results []error
+		want    int
+	}{
+		{"renews until stopped", nil, 5},
+		{"keeps renewing after other error", []error{other, other}, 5},
+		{"stops when lost", []error{nil, lost}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			renewed := make(chan struct{}, 10)
+
+			stop := renewLease("test", 30*time.Millisecond, func() error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				select {
+				case renewed <- struct{}{}:
+				default:
+				}
+				if calls <= len(tt.results) {
+					return tt.results[calls-1]
+				}
+				return nil
+			}, lost)
+
+			for ix := 0; ix < tt.want; ix++ {
+				select {
+				case <-renewed:
+				case <-time.After(time.Second):
+					t.Fatalf("renew called %d times, want %d", ix, tt.want)
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			stop()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.want < 5 && calls != tt.want {
+				t.Errorf("renew called %d times after lease was lost, want %d", calls, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package manago
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns next activation time after given time, zero time means no more activations
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	fieldSecond = cronField{name: "second", min: 0, max: 59}
+	fieldMinute = cronField{name: "minute", min: 0, max: 59}
+	fieldHour   = cronField{name: "hour", min: 0, max: 23}
+	fieldDom    = cronField{name: "day of month", min: 1, max: 31}
+	fieldMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	fieldDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// SpecSchedule is a cron expression schedule, each field is a bit set of allowed values
+type SpecSchedule struct {
+	Second, Minute, Hour, Dom, Month, Dow uint64
+
+	domAny, dowAny bool
+}
+
+// IntervalSchedule activates every Every duration, activations are aligned to multiples of Every (counted from zero time),
+// so all instances calculate the same times (eg. "every 15m" runs at :00, :15, :30 and :45)
+type IntervalSchedule struct {
+	Every time.Duration
+}
+
+func (is IntervalSchedule) Next(from time.Time) time.Time {
+	return from.Truncate(is.Every).Add(is.Every)
+}
+
+// ParseSchedule parses cron expression: 5 fields (minute hour day-of-month month day-of-week),
+// 6 fields (with leading second), descriptors (@yearly, @monthly, @weekly, @daily, @hourly)
+// or interval ("every 15m", "@every 1h30m"). Fields accept *, lists (1,5), ranges (1-5), steps (*/15, 0-30/5)
+// and month or weekday names (jan, mon).
+func ParseSchedule(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(strings.ToLower(expr))
+
+	for _, prefix := range []string{"@every ", "every "} {
+		if strings.HasPrefix(expr, prefix) {
+			every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expr, prefix)))
+			if err != nil {
+				return nil, fmt.Errorf("ParseSchedule: wrong interval in %q: %w", expr, err)
+			}
+			if every < time.Second {
+				return nil, fmt.Errorf("ParseSchedule: interval in %q shorter than 1s", expr)
+			}
+			return IntervalSchedule{Every: every}, nil
+		}
+	}
+
+	descriptor, isDescriptor := cronDescriptors[expr]
+	if isDescriptor {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("ParseSchedule: expected 5 or 6 fields, received %d in %q", len(fields), expr)
+	}
+
+	spec := &SpecSchedule{}
+	var err error
+	targets := []*uint64{&spec.Second, &spec.Minute, &spec.Hour, &spec.Dom, &spec.Month, &spec.Dow}
+	for ix, field := range []cronField{fieldSecond, fieldMinute, fieldHour, fieldDom, fieldMonth, fieldDow} {
+		*targets[ix], err = field.parse(fields[ix])
+		if err != nil {
+			return nil, fmt.Errorf("ParseSchedule %q: %w", expr, err)
+		}
+	}
+
+	// 7 is also sunday
+	if spec.Dow&(1<<7) > 0 {
+		spec.Dow |= 1
+	}
+	spec.domAny = fields[3] == "*" || fields[3] == "?"
+	spec.dowAny = fields[5] == "*" || fields[5] == "?"
+
+	return spec, nil
+}
+
+func (cf cronField) parse(input string) (bits uint64, err error) {
+	for _, part := range strings.Split(input, ",") {
+		rangePart, step := part, 1
+
+		pos := strings.Index(part, "/")
+		if pos > -1 {
+			rangePart = part[:pos]
+			step, err = strconv.Atoi(part[pos+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("wrong step in %s field: %s", cf.name, part)
+			}
+		}
+
+		start, end := cf.min, cf.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			start, err = cf.value(bounds[0])
+			if err != nil {
+				return
+			}
+			end, err = cf.value(bounds[1])
+			if err != nil {
+				return
+			}
+		default:
+			start, err = cf.value(rangePart)
+			if err != nil {
+				return
+			}
+			if pos < 0 {
+				end = start
+			}
+		}
+
+		if start > end {
+			return 0, fmt.Errorf("wrong range in %s field: %s", cf.name, part)
+		}
+
+		for val := start; val <= end; val += step {
+			bits |= 1 << uint(val)
+		}
+	}
+
+	return
+}
+
+func (cf cronField) value(input string) (int, error) {
+	named, isNamed := cf.names[input]
+	if isNamed {
+		return named, nil
+	}
+
+	val, err := strconv.Atoi(input)
+	if err != nil || val < cf.min || val > cf.max {
+		return 0, fmt.Errorf("wrong value in %s field: %s (allowed %d-%d)", cf.name, input, cf.min, cf.max)
+	}
+
+	return val, nil
+}
+
+func (spec *SpecSchedule) Next(from time.Time) time.Time {
+	loc := from.Location()
+	t := from.Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if spec.Month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !spec.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if spec.Hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if spec.Minute&(1<<uint(t.Minute())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+			continue
+		}
+
+		if spec.Second&(1<<uint(t.Second())) == 0 {
+			t = t.Add(time.Second)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+// dayMatches follows cron rule: when both day of month and day of week are restricted, any of them matching is enough
+func (spec *SpecSchedule) dayMatches(t time.Time) bool {
+	domMatch := spec.Dom&(1<<uint(t.Day())) > 0
+	dowMatch := spec.Dow&(1<<uint(t.Weekday())) > 0
+
+	if spec.domAny || spec.dowAny {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
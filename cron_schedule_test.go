@@ -0,0 +1,100 @@
+package manago
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNext(t *testing.T) {
+	// 2024-03-07 is thursday
+	from := time.Date(2024, 3, 7, 10, 20, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2024, 3, 7, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC)},
+		{"30 10 20 * * *", time.Date(2024, 3, 7, 20, 10, 30, 0, time.UTC)},
+		{"*/10 * * * * *", time.Date(2024, 3, 7, 10, 20, 40, 0, time.UTC)},
+		{"0 0 * * mon-fri", time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1-5/2 * *", time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)},
+		// day of month and day of week restricted: any of them matches
+		{"0 0 13 * sat", time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+		{"@daily", time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{" @Hourly ", time.Date(2024, 3, 7, 11, 0, 0, 0, time.UTC)},
+		{"every 15m", time.Date(2024, 3, 7, 10, 30, 0, 0, time.UTC)},
+		{"@every 1h", time.Date(2024, 3, 7, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		schedule, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Errorf("ParseSchedule(%q) failed: %v", tt.expr, err)
+			continue
+		}
+		got := schedule.Next(from)
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseSchedule(%q) Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"every soon",
+		"every 500ms",
+	} {
+		_, err := ParseSchedule(expr)
+		if err == nil {
+			t.Errorf("ParseSchedule(%q) accepted wrong expression", expr)
+		}
+	}
+}
+
+func TestSpecScheduleNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	schedule, err := ParseSchedule("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := schedule.Next(time.Date(2024, 3, 7, 10, 0, 0, 0, loc))
+	want := time.Date(2024, 3, 8, 3, 0, 0, 0, loc)
+	if !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestIntervalScheduleAligned(t *testing.T) {
+	schedule := IntervalSchedule{Every: 7 * time.Minute}
+	first := time.Date(2024, 3, 7, 10, 20, 30, 0, time.UTC)
+
+	want := schedule.Next(first)
+	for _, from := range []time.Time{first.Add(time.Second), first.Add(-time.Minute), first.In(time.FixedZone("UTC+2", 2*60*60))} {
+		got := schedule.Next(from)
+		if !got.Equal(want) {
+			t.Errorf("Next(%v) = %v, want %v (same as other instances)", from, got, want)
+		}
+	}
+	if !want.After(first) || want.Sub(first) > schedule.Every {
+		t.Errorf("Next(%v) = %v, not within next interval", first, want)
+	}
+}
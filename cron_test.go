@@ -0,0 +1,213 @@
+package manago
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hubertat/manago/logging"
+)
+
+type CronTestController struct {
+	Controller
+}
+
+var cronTestRelease = make(chan struct{})
+
+func (ctr *CronTestController) Work() {}
+
+func (ctr *CronTestController) Fail() error {
+	return errors.New("failed")
+}
+
+func (ctr *CronTestController) SetFail() {
+	ctr.SetError(500, errors.New("set failed"))
+}
+
+func (ctr *CronTestController) Panic() {
+	panic("boom")
+}
+
+func (ctr *CronTestController) SlowPanic() {
+	time.Sleep(50 * time.Millisecond)
+	panic("late boom")
+}
+
+func (ctr *CronTestController) Wait() {
+	<-cronTestRelease
+}
+
+func newCronTestManager(t *testing.T) *Manager {
+	return &Manager{
+		Dbc:                  newTestDb(t),
+		Logger:               &logging.NilLogger{},
+		controllersReflected: map[string]reflect.Type{"cron_test": reflect.TypeOf(CronTestController{})},
+	}
+}
+
+func cronOutcomes(cr *Cron) (outcomes []CronOutcome) {
+	for _, run := range cr.Status().History {
+		outcomes = append(outcomes, run.Outcome)
+	}
+	return
+}
+
+func TestCronRunMethodOutcomes(t *testing.T) {
+	man := newCronTestManager(t)
+
+	tests := []struct {
+		method  string
+		timeout time.Duration
+		want    []CronOutcome
+	}{
+		{"Work", 0, []CronOutcome{CronOk}},
+		{"Fail", 0, []CronOutcome{CronError}},
+		{"SetFail", 0, []CronOutcome{CronError}},
+		{"Panic", 0, []CronOutcome{CronPanic}},
+		// newest first: final outcome is recorded after timeout
+		{"SlowPanic", 10 * time.Millisecond, []CronOutcome{CronPanic, CronTimeout}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			cr := NewCron("cron_test", tt.method)
+			cr.Timeout = tt.timeout
+
+			err := cr.RunMethod(man)
+			if err != nil {
+				t.Fatal(err)
+			}
+			man.background.Wait()
+
+			got := cronOutcomes(&cr)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outcomes = %v, want %v", got, tt.want)
+			}
+			if cr.Status().Running != 0 {
+				t.Errorf("running = %d after run finished", cr.Status().Running)
+			}
+		})
+	}
+}
+
+func TestCronRunMethodPrepareFailure(t *testing.T) {
+	man := newCronTestManager(t)
+	cr := NewCron("cron_test", "Missing")
+
+	err := cr.RunMethod(man)
+	if err == nil {
+		t.Fatal("RunMethod started missing method")
+	}
+
+	status := cr.Status()
+	if status.LastRun == nil || status.LastRun.Outcome != CronError || status.Running != 0 {
+		t.Errorf("status after failed start = %+v", status)
+	}
+}
+
+func TestCronOverlap(t *testing.T) {
+	man := newCronTestManager(t)
+
+	tests := []struct {
+		overlap OverlapPolicy
+		dbLocks bool
+		want    []CronOutcome
+	}{
+		{OverlapSkip, false, []CronOutcome{CronOk, CronSkipped}},
+		{OverlapQueue, false, []CronOutcome{CronOk, CronOk}},
+		{OverlapAllow, false, []CronOutcome{CronOk, CronOk}},
+		{OverlapQueue, true, []CronOutcome{CronOk, CronOk}},
+		{OverlapAllow, true, []CronOutcome{CronOk, CronSkipped}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s db locks %v", tt.overlap, tt.dbLocks), func(t *testing.T) {
+			man.cronLocks = nil
+			if tt.dbLocks {
+				man.cronLocks = newCronLocker(man.Dbc, time.Minute)
+				man.Dbc.DB.Exec("DELETE FROM manago_cron_locks")
+			}
+			cronTestRelease = make(chan struct{})
+			cr := NewCron("cron_test", "Wait")
+			cr.Overlap = tt.overlap
+
+			for ix := 0; ix < 2; ix++ {
+				err := cr.RunMethod(man)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			close(cronTestRelease)
+			man.background.Wait()
+
+			got := cronOutcomes(&cr)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("outcomes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronStateWithoutConstructor(t *testing.T) {
+	minute := 5
+	cr := &Cron{ControllerName: "cron_test", MethodName: "Work", Minute: &minute}
+
+	var wg sync.WaitGroup
+	for ix := 0; ix < 4; ix++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cr.CheckTime()
+			cr.Status()
+		}()
+	}
+	wg.Wait()
+
+	if cr.NextRun().Minute() != minute {
+		t.Errorf("NextRun = %v, want minute %d", cr.NextRun(), minute)
+	}
+}
+
+func TestCronFromConfig(t *testing.T) {
+	man := newCronTestManager(t)
+	disabled := false
+
+	tests := []struct {
+		name string
+		conf CronConfig
+		err  bool
+		want func(cr Cron) bool
+	}{
+		{"defaults", CronConfig{Controller: "cron_test", Method: "Work", Schedule: "*/5 * * * *"}, false, func(cr Cron) bool {
+			return cr.Overlap == "" && cr.Timeout == 0 && !cr.Disabled && cr.Location == nil
+		}},
+		{"options", CronConfig{Controller: "cron_test", Method: "Work", Schedule: "@daily", Timezone: "UTC", Overlap: "Queue", TimeoutSeconds: 30, Enabled: &disabled}, false, func(cr Cron) bool {
+			return cr.Overlap == OverlapQueue && cr.Timeout == 30*time.Second && cr.Disabled && cr.Location == time.UTC
+		}},
+		{"unknown controller", CronConfig{Controller: "missing", Method: "Work", Schedule: "@daily"}, true, nil},
+		{"unknown method", CronConfig{Controller: "cron_test", Method: "Missing", Schedule: "@daily"}, true, nil},
+		{"wrong schedule", CronConfig{Controller: "cron_test", Method: "Work", Schedule: "daily"}, true, nil},
+		{"wrong timezone", CronConfig{Controller: "cron_test", Method: "Work", Schedule: "@daily", Timezone: "Nowhere/City"}, true, nil},
+		{"wrong overlap", CronConfig{Controller: "cron_test", Method: "Work", Schedule: "@daily", Overlap: "wait"}, true, nil},
+		{"allow with db locks", CronConfig{Controller: "cron_test", Method: "Work", Schedule: "@daily", Overlap: "allow"}, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			man.Config.CronLock = ""
+			if tt.name == "allow with db locks" {
+				man.Config.CronLock = "db"
+			}
+			cr, err := man.cronFromConfig(tt.conf)
+			if (err != nil) != tt.err {
+				t.Fatalf("cronFromConfig error = %v, want error %v", err, tt.err)
+			}
+			if tt.want != nil && !tt.want(cr) {
+				t.Errorf("cronFromConfig = %+v", cr)
+			}
+		})
+	}
+}
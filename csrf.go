@@ -0,0 +1,104 @@
+package manago
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+)
+
+const (
+	sessionKeyCsrf = "_csrf_token"
+
+	// CsrfFieldName is form field and CsrfHeaderName request header checked for token
+	CsrfFieldName  = "_csrf"
+	CsrfHeaderName = "X-CSRF-Token"
+)
+
+// csrfToken returns token kept in session, generating new one when missing
+func (man *Manager) csrfToken(r *http.Request) (string, error) {
+	token := man.sessionManager.GetString(r.Context(), sessionKeyCsrf)
+	if len(token) > 0 {
+		return token, nil
+	}
+
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return "", fmt.Errorf("generating csrf token failed: %w", err)
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(random)
+	man.sessionManager.Put(r.Context(), sessionKeyCsrf, token)
+
+	return token, nil
+}
+
+// CsrfExempt disables csrf check for selected controller methods (eg. json api used by other clients)
+func (man *Manager) CsrfExempt(ctrName string, methods ...string) {
+	if man.csrfExempt == nil {
+		man.csrfExempt = make(map[string]bool)
+	}
+
+	for _, mtdName := range methods {
+		man.csrfExempt[ctrName+"."+mtdName] = true
+	}
+}
+
+// verifyCsrf puts token in view content as CsrfToken and for unsafe http methods compares it with token sent
+// in form field or header. Requests with valid api key and exempted methods are not checked.
+// Sets 403 error on controller and returns false when check fails.
+func (man *Manager) verifyCsrf(ctrName, mtdName string, ctr Controlled, r *http.Request) bool {
+	if !man.Config.Csrf {
+		return true
+	}
+
+	token, err := man.csrfToken(r)
+	if err != nil {
+		ctr.SetError(500, err)
+		return false
+	}
+	(*ctr.Ctnt())["CsrfToken"] = token
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+
+	if man.csrfExempt[ctrName+"."+mtdName] || ctr.VerifyApiKey() {
+		return true
+	}
+
+	sent := r.Header.Get(CsrfHeaderName)
+	if len(sent) == 0 {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "multipart/form-data" {
+			// parse within body limit, FormValue would accept up to 32MB
+			maxBytes := man.maxBodyBytes()
+			r.Body = http.MaxBytesReader(nil, r.Body, maxBytes)
+			err = r.ParseMultipartForm(maxBytes)
+			if err != nil {
+				setBodyError(ctr, err)
+				return false
+			}
+		}
+		sent = r.FormValue(CsrfFieldName)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+		log.Printf("Manager verifyCsrf: token mismatch for %s %s", r.Method, r.URL.Path)
+		ctr.SetError(403, fmt.Errorf("csrf token missing or invalid"), "Invalid CSRF token, please reload page and try again")
+		return false
+	}
+
+	return true
+}
+
+// tCsrfField renders hidden input with csrf token: {{csrfField .CsrfToken}}
+func tCsrfField(token string) template.HTML {
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, CsrfFieldName, template.HTMLEscapeString(token)))
+}
@@ -0,0 +1,118 @@
+package manago
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+func newCsrfRequest(t *testing.T, man *Manager, method string, form url.Values, header string) *http.Request {
+	ctx, err := man.sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	man.sessionManager.Put(ctx, sessionKeyCsrf, "token")
+
+	r := httptest.NewRequest(method, "/items", strings.NewReader(form.Encode())).WithContext(ctx)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(header) > 0 {
+		r.Header.Set(CsrfHeaderName, header)
+	}
+	return r
+}
+
+func TestVerifyCsrf(t *testing.T) {
+	apiKey := "key"
+	man := &Manager{Config: Config{Csrf: true, ApiKey: &apiKey}, sessionManager: scs.New()}
+	man.CsrfExempt("Items", "Hook")
+
+	tests := []struct {
+		name   string
+		method string
+		mtd    string
+		form   url.Values
+		header string
+		want   bool
+	}{
+		{"safe method", http.MethodGet, "Index", nil, "", true},
+		{"missing token", http.MethodPost, "Create", nil, "", false},
+		{"form token", http.MethodPost, "Create", url.Values{CsrfFieldName: {"token"}}, "", true},
+		{"header token", http.MethodDelete, "Delete", nil, "token", true},
+		{"wrong token", http.MethodPost, "Create", url.Values{CsrfFieldName: {"other"}}, "", false},
+		{"exempt method", http.MethodPost, "Hook", nil, "", true},
+		{"api key", http.MethodPost, "Create", url.Values{"api_key": {"key"}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newCsrfRequest(t, man, tt.method, tt.form, tt.header)
+			ctr := &Controller{Man: man}
+			ctr.SetReqData(r, nil)
+
+			got := man.verifyCsrf("Items", tt.mtd, ctr, r)
+			if got != tt.want {
+				t.Errorf("verifyCsrf = %v, want %v", got, tt.want)
+			}
+			if !got && ctr.E.Code != 403 {
+				t.Errorf("error code = %d, want 403", ctr.E.Code)
+			}
+			if (*ctr.Ctnt())["CsrfToken"] != "token" {
+				t.Errorf("CsrfToken not set in view content")
+			}
+		})
+	}
+}
+
+func TestVerifyCsrfDisabled(t *testing.T) {
+	man := &Manager{sessionManager: scs.New()}
+	r := newCsrfRequest(t, man, http.MethodPost, nil, "")
+	ctr := &Controller{Man: man}
+	ctr.SetReqData(r, nil)
+
+	if !man.verifyCsrf("Items", "Create", ctr, r) {
+		t.Error("verifyCsrf failed with Csrf disabled")
+	}
+}
+
+func TestCsrfField(t *testing.T) {
+	got := string(tCsrfField(`a"b`))
+	want := `<input type="hidden" name="_csrf" value="a&#34;b">`
+	if got != want {
+		t.Errorf("tCsrfField = %s, want %s", got, want)
+	}
+}
+
+func TestVerifyCsrfMultipartLimit(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField(CsrfFieldName, "token")
+	mw.WriteField("text", strings.Repeat("a", 1000))
+	mw.Close()
+
+	man := &Manager{Config: Config{Csrf: true, MaxBodyBytes: 512}, sessionManager: scs.New()}
+	ctx, err := man.sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	man.sessionManager.Put(ctx, sessionKeyCsrf, "token")
+
+	r := httptest.NewRequest(http.MethodPost, "/items", body).WithContext(ctx)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	ctr := &Controller{Man: man}
+	ctr.SetReqData(r, nil)
+
+	if man.verifyCsrf("Items", "Create", ctr, r) {
+		t.Fatal("verifyCsrf accepted body over limit")
+	}
+	if ctr.E.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("error code = %d, want %d", ctr.E.Code, http.StatusRequestEntityTooLarge)
+	}
+}
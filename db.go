@@ -1,30 +1,117 @@
 package manago
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"time"
 
+	"github.com/hubertat/manago/logging"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/mssql"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
 )
 
+const defaultSqliteBusyTimeout = 5000
+
 type Db struct {
 	DB *gorm.DB
 
 	config DatabaseConfig
 }
 
+// Check connects to configured database and keeps connection pool open in DB, it should be closed with Close.
 func (dbc *Db) Check(config DatabaseConfig) (err error) {
 
 	dbc.config = config
 
-	_, err = dbc.Open()
+	if dbc.DB != nil {
+		dbc.Close()
+	}
+
+	db, err := dbc.connect()
+	if err != nil {
+		return
+	}
+
+	pool := db.DB()
+	if config.MaxOpenConns > 0 {
+		pool.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		pool.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetimeMinutes > 0 {
+		pool.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetimeMinutes) * time.Minute)
+	}
+
+	dbc.DB = db
 	return
 }
 
+// Open returns new session using shared connection pool, closing it is not needed.
 func (dbc *Db) Open() (db *gorm.DB, err error) {
+	if dbc.DB == nil {
+		err = fmt.Errorf("database not connected, cant open session (%s)", dbc.config.Server)
+		return
+	}
+
+	db = dbc.DB.New()
+	return
+}
+
+// WithContext returns new session using shared connection pool with ctx passed to database driver: query running
+// when ctx is done is interrupted and following ones fail with ctx error, transactions are started with ctx
+// (statement already running in transaction completes, then transaction is rolled back).
+// Session is built over context aware SQLCommon, so its DB() is not available (CommonDB gives ctx aware Exec and Query)
+// and callbacks or LogMode changed on Db.DB are not applied to it.
+func (dbc *Db) WithContext(ctx context.Context) (db *gorm.DB, err error) {
+	if dbc.DB == nil {
+		err = fmt.Errorf("database not connected, cant open session (%s)", dbc.config.Server)
+		return
+	}
+
+	return gorm.Open(dbc.DB.Dialect().GetName(), &ctxSqlDb{ctx: ctx, db: dbc.DB.DB()})
+}
+
+// ctxSqlDb passes context to every query and transaction of pooled sql.DB, used as gorm SQLCommon
+type ctxSqlDb struct {
+	ctx context.Context
+	db  *sql.DB
+}
+
+func (cdb *ctxSqlDb) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return cdb.db.ExecContext(cdb.ctx, query, args...)
+}
+
+func (cdb *ctxSqlDb) Prepare(query string) (*sql.Stmt, error) {
+	return cdb.db.PrepareContext(cdb.ctx, query)
+}
+
+func (cdb *ctxSqlDb) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return cdb.db.QueryContext(cdb.ctx, query, args...)
+}
+
+func (cdb *ctxSqlDb) QueryRow(query string, args ...interface{}) *sql.Row {
+	return cdb.db.QueryRowContext(cdb.ctx, query, args...)
+}
+
+func (cdb *ctxSqlDb) Begin() (*sql.Tx, error) {
+	return cdb.db.BeginTx(cdb.ctx, nil)
+}
+
+// BeginTx starts transaction with session ctx, ctx passed by gorm (context.Background) is ignored
+func (cdb *ctxSqlDb) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return cdb.db.BeginTx(cdb.ctx, opts)
+}
+
+func (dbc *Db) connect() (db *gorm.DB, err error) {
 	switch dbc.config.Server {
 	case "postgres":
 		if dbc.config.DisableSsl {
@@ -43,18 +130,92 @@ func (dbc *Db) Open() (db *gorm.DB, err error) {
 		}
 		db, err = gorm.Open("mssql", dsn)
 
+	case "sqlite", "sqlite3":
+		var dsn string
+		dsn, err = dbc.sqliteDsn()
+		if err != nil {
+			return
+		}
+		db, err = gorm.Open("sqlite3", dsn)
+
 	default:
 		db, err = nil, fmt.Errorf("database type not found: %v, cant connect", dbc.config)
 
 	}
 
-	dbc.DB = db
 	return
 }
 
-func (dbc *Db) Close() {
+// sqliteDsn prepares parent directory for database file and returns dsn with busy timeout and journal mode options
+func (dbc *Db) sqliteDsn() (dsn string, err error) {
+	if len(dbc.config.SqlitePath) == 0 {
+		err = fmt.Errorf("sqlite database path (SqlitePath) not set, cant connect")
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(dbc.config.SqlitePath), 0755)
+	if err != nil {
+		err = fmt.Errorf("creating directory for sqlite database failed: %w", err)
+		return
+	}
+
+	options := url.Values{}
+	busyTimeout := dbc.config.SqliteBusyTimeout
+	if busyTimeout == 0 {
+		busyTimeout = defaultSqliteBusyTimeout
+	}
+	options.Set("_busy_timeout", fmt.Sprint(busyTimeout))
+	if dbc.config.SqliteWal {
+		options.Set("_journal_mode", "WAL")
+	}
+
+	dsn = dbc.config.SqlitePath + "?" + options.Encode()
+	return
 }
 
+// Close closes connection pool, Check must be called again to use Db.
+func (dbc *Db) Close() (err error) {
+	if dbc.DB == nil {
+		return
+	}
+
+	err = dbc.DB.Close()
+	dbc.DB = nil
+	return
+}
+
+func (dbc *Db) Stats() sql.DBStats {
+	if dbc.DB == nil {
+		return sql.DBStats{}
+	}
+
+	return dbc.DB.DB().Stats()
+}
+
+// LogStats sends connection pool statistics as "db_pool" measurement
+func (dbc *Db) LogStats(logger logging.Logger) {
+	if dbc.DB == nil {
+		return
+	}
+
+	stats := dbc.Stats()
+	tags := map[string]string{
+		"server": dbc.config.Server,
+	}
+	fields := map[string]interface{}{
+		"max_open":            stats.MaxOpenConnections,
+		"open":                stats.OpenConnections,
+		"in_use":              stats.InUse,
+		"idle":                stats.Idle,
+		"wait_count":          stats.WaitCount,
+		"wait_duration_ms":    stats.WaitDuration.Milliseconds(),
+		"max_idle_closed":     stats.MaxIdleClosed,
+		"max_lifetime_closed": stats.MaxLifetimeClosed,
+	}
+	logger.LogMeasurement("db_pool", tags, fields)
+}
+
+// AutoMigrate migrates every model, errors of failed models are returned joined
 func (dbc *Db) AutoMigrate(modelsReflected map[string]reflect.Type) (err error) {
 
 	db, err := dbc.Open()
@@ -66,7 +227,10 @@ func (dbc *Db) AutoMigrate(modelsReflected map[string]reflect.Type) (err error)
 	for _, v := range modelsReflected {
 		model := reflect.New(v).Interface()
 		fmt.Printf("Migrating model: %v\n", v)
-		db.AutoMigrate(model)
+		errModel := db.AutoMigrate(model).Error
+		if errModel != nil {
+			err = errors.Join(err, fmt.Errorf("migrating %v failed: %w", v, errModel))
+		}
 	}
 
 	return
@@ -77,13 +241,11 @@ func (dbc *Db) CopyDb(modelsReflected map[string]reflect.Type, targetDb *Db) err
 	if err != nil {
 		return errors.Join(errors.New("failed to open source db"), err)
 	}
-	defer source.Close()
 
 	target, err := targetDb.Open()
 	if err != nil {
 		return errors.Join(errors.New("failed to open target db"), err)
 	}
-	defer target.Close()
 
 	for _, v := range modelsReflected {
 		model := reflect.New(v).Interface()
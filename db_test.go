@@ -0,0 +1,138 @@
+package manago
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type dbTestItem struct {
+	ID   uint
+	Name string
+}
+
+// newTestDb returns sqlite database in test temp directory with internal tables and models migrated
+func newTestDb(t *testing.T, models ...interface{}) *Db {
+	t.Helper()
+
+	dbc := &Db{}
+	err := dbc.Check(DatabaseConfig{Server: "sqlite", SqlitePath: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbc.Close() })
+
+	internal := []interface{}{&SequenceCounter{}, &CronLock{}, &Job{}, &SessionData{}}
+	err = dbc.DB.AutoMigrate(append(internal, models...)...).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dbc
+}
+
+func TestDbWithContext(t *testing.T) {
+	dbc := newTestDb(t, &dbTestItem{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	db, err := dbc.WithContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cdb, ok := db.CommonDB().(*ctxSqlDb)
+	if !ok || cdb.db != dbc.DB.DB() {
+		t.Error("session does not use connection pool of Db")
+	}
+
+	err = db.Create(&dbTestItem{Name: "first"}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"create", func() error { return db.Create(&dbTestItem{Name: "second"}).Error }},
+		{"query", func() error { return db.Find(&[]dbTestItem{}).Error }},
+		{"update", func() error { return db.Model(&dbTestItem{ID: 1}).Update("name", "changed").Error }},
+		{"delete", func() error { return db.Delete(&dbTestItem{ID: 1}).Error }},
+		{"exec", func() error { return db.Exec("DELETE FROM db_test_items").Error }},
+		{"row", func() error { return db.Raw("SELECT count(*) FROM db_test_items").Row().Scan(new(int)) }},
+		{"transaction", func() error { return db.Begin().Error }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("error = %v, want context.Canceled", err)
+			}
+		})
+	}
+
+	items := []dbTestItem{}
+	err = dbc.DB.Find(&items).Error
+	if err != nil || len(items) != 1 || items[0].Name != "first" {
+		t.Errorf("items after cancel = %+v (%v), want only unchanged first", items, err)
+	}
+}
+
+// slowQuery counts to 10^9 in sqlite, running for many seconds unless interrupted
+const slowQuery = "WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c WHERE x < 1000000000) SELECT count(*) FROM c"
+
+func TestDbWithContextInterruptsQuery(t *testing.T) {
+	dbc := newTestDb(t)
+
+	tests := []struct {
+		name string
+		run  func(db *gorm.DB) error
+	}{
+		{"row", func(db *gorm.DB) error { return db.Raw(slowQuery).Row().Scan(new(int)) }},
+		{"rows", func(db *gorm.DB) error {
+			rows, err := db.Raw(slowQuery).Rows()
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+			}
+			return rows.Err()
+		}},
+		{"scan", func(db *gorm.DB) error { return db.Raw(slowQuery).Scan(&struct{ Count int }{}).Error }},
+		{"exec", func(db *gorm.DB) error { return db.Exec("CREATE TABLE slow AS " + slowQuery).Error }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			db, err := dbc.WithContext(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			started := time.Now()
+			err = tt.run(db)
+			if err == nil {
+				t.Fatal("slow query finished without error")
+			}
+			if elapsed := time.Since(started); elapsed > 5*time.Second {
+				t.Errorf("query interrupted after %v, want shortly after timeout", elapsed)
+			}
+		})
+	}
+
+	var one int
+	err := dbc.DB.Raw("SELECT 1").Row().Scan(&one)
+	if err != nil || one != 1 {
+		t.Errorf("pool after interrupted queries: %d (%v)", one, err)
+	}
+}
@@ -0,0 +1,49 @@
+package manago
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const sessionKeyFlashes = "_flashes"
+
+// FlashMessage is shown once, on the next rendered template, as element of "Flashes" view content
+type FlashMessage struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Flash adds message of kind (eg. "success", "error") kept in session until next template is rendered,
+// so it survives SetRedir redirect
+func (ctr *Controller) Flash(kind string, msg string) {
+	ctx := ctr.Req.R.Context()
+
+	flashes := decodeFlashes(ctr.Man.sessionManager.GetString(ctx, sessionKeyFlashes))
+	flashes = append(flashes, FlashMessage{Kind: kind, Message: msg})
+
+	encoded, err := json.Marshal(flashes)
+	if err != nil {
+		log.Printf("Controller Flash: encoding failed: %v", err)
+		return
+	}
+	ctr.Man.sessionManager.Put(ctx, sessionKeyFlashes, string(encoded))
+}
+
+// popFlashes removes flash messages from session returning them
+func (man *Manager) popFlashes(r *http.Request) []FlashMessage {
+	return decodeFlashes(man.sessionManager.PopString(r.Context(), sessionKeyFlashes))
+}
+
+func decodeFlashes(encoded string) (flashes []FlashMessage) {
+	if len(encoded) == 0 {
+		return
+	}
+
+	err := json.Unmarshal([]byte(encoded), &flashes)
+	if err != nil {
+		log.Printf("decodeFlashes: dropping malformed flashes: %v", err)
+	}
+
+	return
+}
@@ -0,0 +1,112 @@
+package manago
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// newSessionController returns controller with session loaded from token (new session when empty)
+func newSessionController(t *testing.T, man *Manager, token string) *Controller {
+	ctx, err := man.sessionManager.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctr := &Controller{Man: man}
+	ctr.SetReqData(httptest.NewRequest("GET", "/", nil).WithContext(ctx), nil)
+	return ctr
+}
+
+// commitSession saves session of controller returning its token
+func commitSession(t *testing.T, ctr *Controller) string {
+	token, _, err := ctr.Man.sessionManager.Commit(ctr.Req.R.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestFlash(t *testing.T) {
+	man := &Manager{sessionManager: scs.New()}
+
+	ctr := newSessionController(t, man, "")
+	ctr.Flash("success", "Saved")
+	ctr.Flash("error", "Mail not sent")
+	token := commitSession(t, ctr)
+
+	// next request (after redirect) gets messages once
+	ctr = newSessionController(t, man, token)
+	want := []FlashMessage{{"success", "Saved"}, {"error", "Mail not sent"}}
+	got := man.popFlashes(ctr.Req.R)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("popFlashes = %v, want %v", got, want)
+	}
+	if got = man.popFlashes(ctr.Req.R); len(got) > 0 {
+		t.Errorf("flashes shown twice: %v", got)
+	}
+}
+
+func TestDecodeFlashes(t *testing.T) {
+	tests := []struct {
+		encoded string
+		want    []FlashMessage
+	}{
+		{"", nil},
+		{`[{"kind":"info","message":"Hi"}]`, []FlashMessage{{"info", "Hi"}}},
+		{`not json`, nil},
+	}
+
+	for _, tt := range tests {
+		got := decodeFlashes(tt.encoded)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("decodeFlashes(%q) = %v, want %v", tt.encoded, got, tt.want)
+		}
+	}
+}
+
+func TestSessionAccessors(t *testing.T) {
+	man := &Manager{sessionManager: scs.New()}
+	at := time.Date(2024, 3, 7, 10, 15, 30, 500, time.UTC)
+	type prefs struct {
+		Theme string
+		Size  int
+	}
+
+	ctr := newSessionController(t, man, "")
+	ctr.SessionSet("name", "john")
+	ctr.SessionSetInt("count", 3)
+	ctr.SessionSetBool("admin", true)
+	ctr.SessionSetTime("seen", at)
+	err := ctr.SessionSetJson("prefs", prefs{"dark", 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := commitSession(t, ctr)
+
+	ctr = newSessionController(t, man, token)
+	if ctr.SessionGet("name") != "john" || ctr.SessionGetInt("count") != 3 || !ctr.SessionGetBool("admin") {
+		t.Error("basic values not kept in session")
+	}
+	if got := ctr.SessionGetTime("seen"); !got.Equal(at) {
+		t.Errorf("SessionGetTime = %v, want %v", got, at)
+	}
+
+	got := prefs{}
+	found, err := ctr.SessionGetJson("prefs", &got)
+	if err != nil || !found || got != (prefs{"dark", 2}) {
+		t.Errorf("SessionGetJson = %+v, %v, %v", got, found, err)
+	}
+
+	if ctr.SessionGetInt("name") != 0 || !ctr.SessionGetTime("missing").IsZero() {
+		t.Error("missing or other type values should give zero values")
+	}
+	found, err = ctr.SessionGetJson("missing", &got)
+	if found || err != nil {
+		t.Errorf("SessionGetJson of missing key = %v, %v", found, err)
+	}
+}
@@ -0,0 +1,299 @@
+package manago
+
+import (
+	"database/sql"
+	"encoding"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/gorm"
+)
+
+// FormConverter converts form values of a field into value of typ
+type FormConverter func(vals []string, typ reflect.Type) (reflect.Value, error)
+
+// FormTimeLayouts are tried in order when parsing time fields (as UTC unless layout has zone), single field
+// can set own layout with `layout:"02.01.2006 15:04"` struct tag
+var FormTimeLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+}
+
+var formConverters = struct {
+	sync.RWMutex
+	byType map[reflect.Type]FormConverter
+}{byType: map[reflect.Type]FormConverter{
+	reflect.TypeOf(sql.NullTime{}): convertNullTime,
+}}
+
+var (
+	typeTime            = reflect.TypeOf(time.Time{})
+	typeGormModel       = reflect.TypeOf(gorm.Model{})
+	typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	typeScanner         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// RegisterFormConverter sets converter used by FillModel for fields of typ (takes precedence over built-in conversions)
+func RegisterFormConverter(typ reflect.Type, converter FormConverter) {
+	formConverters.Lock()
+	defer formConverters.Unlock()
+
+	formConverters.byType[typ] = converter
+}
+
+// FillForm sets model (pointer to struct) fields from form values named as snake case field names.
+// Supports basic kinds, time.Time, pointers (empty value sets nil), sql.Null* types, encoding.TextUnmarshaler,
+// embedded structs (gorm.Model is skipped), slices (from multiple values) and types with registered converter.
+// Empty values of non-pointer fields and ID field are skipped, values which failed to parse are returned as errors.
+func FillForm(model interface{}, form url.Values) (valuesCount int, errs ValidationErrors) {
+	errs = ValidationErrors{}
+
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		log.Printf("FillForm: expected pointer to struct, received %T", model)
+		return
+	}
+
+	valuesCount = fillStruct(v.Elem(), form, errs)
+	return
+}
+
+func fillStruct(v reflect.Value, form url.Values, errs ValidationErrors) (valuesCount int) {
+	for ix := 0; ix < v.NumField(); ix++ {
+		field := v.Type().Field(ix)
+		if !v.Field(ix).CanSet() || field.Name == "ID" {
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && !isFormValueType(field.Type) {
+			if field.Type != typeGormModel {
+				valuesCount += fillStruct(v.Field(ix), form, errs)
+			}
+			continue
+		}
+
+		formName := strcase.ToSnake(field.Name)
+		vals, exist := form[formName]
+		if !exist || len(vals) == 0 {
+			continue
+		}
+
+		value, set, err := convertForm(vals, field.Type, field.Tag.Get("layout"))
+		if err != nil {
+			errs.Add(formName, err.Error())
+			continue
+		}
+		if set {
+			v.Field(ix).Set(value)
+			valuesCount++
+		}
+	}
+
+	return
+}
+
+// isFormValueType reports struct types converted from single value instead of being filled field by field
+func isFormValueType(typ reflect.Type) bool {
+	formConverters.RLock()
+	_, registered := formConverters.byType[typ]
+	formConverters.RUnlock()
+
+	ptr := reflect.PtrTo(typ)
+	return registered || typ == typeTime || ptr.Implements(typeTextUnmarshaler) || ptr.Implements(typeScanner)
+}
+
+// convertForm returns converted value and set=false when value should be skipped
+func convertForm(vals []string, typ reflect.Type, layout string) (value reflect.Value, set bool, err error) {
+	formConverters.RLock()
+	converter, registered := formConverters.byType[typ]
+	formConverters.RUnlock()
+
+	if registered {
+		value, err = converter(vals, typ)
+		return value, err == nil, err
+	}
+
+	// strings keep whitespace as sent (passwords, textareas), parsed values are trimmed
+	formVal := vals[0]
+	ptr := reflect.PtrTo(typ)
+
+	switch {
+	case typ == typeTime:
+		trimmed := strings.TrimSpace(formVal)
+		if len(trimmed) == 0 {
+			return
+		}
+		var tm time.Time
+		tm, err = parseFormTime(trimmed, layout)
+		return reflect.ValueOf(tm), err == nil, err
+
+	case ptr.Implements(typeTextUnmarshaler):
+		if len(formVal) == 0 {
+			return
+		}
+		value = reflect.New(typ)
+		err = value.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(formVal))
+		if err != nil {
+			err = fmt.Errorf("has wrong format")
+			return
+		}
+		return value.Elem(), true, nil
+
+	case ptr.Implements(typeScanner):
+		value = reflect.New(typ)
+		if len(formVal) > 0 {
+			err = value.Interface().(sql.Scanner).Scan(formVal)
+			if err != nil {
+				err = fmt.Errorf("has wrong format")
+				return
+			}
+		}
+		return value.Elem(), true, nil
+
+	case typ.Kind() == reflect.Ptr:
+		if len(formVal) == 0 || (typ.Elem().Kind() != reflect.String && len(strings.TrimSpace(formVal)) == 0) {
+			return reflect.Zero(typ), true, nil
+		}
+		var elem reflect.Value
+		elem, set, err = convertForm(vals, typ.Elem(), layout)
+		if !set || err != nil {
+			return
+		}
+		value = reflect.New(typ.Elem())
+		value.Elem().Set(elem)
+		return value, true, nil
+
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8:
+		value = reflect.MakeSlice(typ, 0, len(vals))
+		for _, val := range vals {
+			elem, elemSet, elemErr := convertForm([]string{val}, typ.Elem(), layout)
+			if elemErr != nil {
+				return value, false, elemErr
+			}
+			if elemSet {
+				value = reflect.Append(value, elem)
+			}
+		}
+		return value, true, nil
+	}
+
+	return convertKind(formVal, typ)
+}
+
+func convertKind(formVal string, typ reflect.Type) (value reflect.Value, set bool, err error) {
+	value = reflect.New(typ).Elem()
+	if typ.Kind() != reflect.String && typ.Kind() != reflect.Slice {
+		formVal = strings.TrimSpace(formVal)
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		if len(formVal) == 0 {
+			return
+		}
+		value.SetString(formVal)
+
+	case reflect.Slice:
+		value.SetBytes([]byte(formVal))
+
+	case reflect.Bool:
+		if len(formVal) == 0 {
+			return
+		}
+		bVal, errBool := strconv.ParseBool(formVal)
+		if errBool != nil && formVal != "on" {
+			err = fmt.Errorf("must be true or false")
+			return
+		}
+		value.SetBool(bVal || formVal == "on")
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if len(formVal) == 0 {
+			return
+		}
+		if typ == reflect.TypeOf(time.Duration(0)) {
+			dVal, errDur := time.ParseDuration(formVal)
+			if errDur != nil {
+				err = fmt.Errorf("must be a duration (eg. 1h30m)")
+				return
+			}
+			value.SetInt(int64(dVal))
+			break
+		}
+		iVal, errInt := strconv.ParseInt(formVal, 10, typ.Bits())
+		if errInt != nil {
+			err = fmt.Errorf("must be a whole number")
+			return
+		}
+		value.SetInt(iVal)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if len(formVal) == 0 {
+			return
+		}
+		uVal, errUint := strconv.ParseUint(formVal, 10, typ.Bits())
+		if errUint != nil {
+			err = fmt.Errorf("must be a positive whole number")
+			return
+		}
+		value.SetUint(uVal)
+
+	case reflect.Float32, reflect.Float64:
+		if len(formVal) == 0 {
+			return
+		}
+		fVal, errFloat := strconv.ParseFloat(strings.Replace(formVal, ",", ".", 1), typ.Bits())
+		if errFloat != nil {
+			err = fmt.Errorf("must be a number")
+			return
+		}
+		value.SetFloat(fVal)
+
+	default:
+		return
+	}
+
+	return value, true, nil
+}
+
+func parseFormTime(formVal string, layout string) (tm time.Time, err error) {
+	layouts := FormTimeLayouts
+	if len(layout) > 0 {
+		layouts = []string{layout}
+	}
+
+	for _, tryLayout := range layouts {
+		tm, err = time.Parse(tryLayout, formVal)
+		if err == nil {
+			return
+		}
+	}
+
+	err = fmt.Errorf("must be a date (%s)", layouts[0])
+	return
+}
+
+func convertNullTime(vals []string, typ reflect.Type) (reflect.Value, error) {
+	formVal := strings.TrimSpace(vals[0])
+	if len(formVal) == 0 {
+		return reflect.ValueOf(sql.NullTime{}), nil
+	}
+
+	tm, err := parseFormTime(formVal, "")
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	return reflect.ValueOf(sql.NullTime{Time: tm, Valid: true}), nil
+}
@@ -0,0 +1,108 @@
+package manago
+
+import (
+	"database/sql"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type FormBase struct {
+	Note string
+}
+
+type formModel struct {
+	FormBase
+	ID       uint
+	Password string
+	Count    int
+	Ratio    float64
+	Active   bool
+	Timeout  time.Duration
+	Born     time.Time
+	Meeting  time.Time `layout:"02.01.2006 15:04"`
+	Limit    *int
+	Removed  sql.NullTime
+	Ids      []uint
+}
+
+func TestFillForm(t *testing.T) {
+	limit := 3
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		want   formModel
+		count  int
+		errors ValidationErrors
+	}{
+		{"strings keep whitespace", url.Values{"password": {"  secret  "}, "note": {" line\n"}},
+			formModel{FormBase: FormBase{" line\n"}, Password: "  secret  "}, 2, ValidationErrors{}},
+		{"parsed values are trimmed", url.Values{"count": {" 12 "}, "ratio": {" 1,5"}, "active": {"on "}, "timeout": {" 1h30m"}},
+			formModel{Count: 12, Ratio: 1.5, Active: true, Timeout: 90 * time.Minute}, 4, ValidationErrors{}},
+		{"times in utc", url.Values{"born": {" 2020-05-17 "}, "meeting": {"01.02.2021 10:30"}, "removed": {"2021-03-04T05:06"}},
+			formModel{
+				Born:    time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC),
+				Meeting: time.Date(2021, 2, 1, 10, 30, 0, 0, time.UTC),
+				Removed: sql.NullTime{Time: time.Date(2021, 3, 4, 5, 6, 0, 0, time.UTC), Valid: true},
+			}, 3, ValidationErrors{}},
+		{"pointer", url.Values{"limit": {"3"}}, formModel{Limit: &limit}, 1, ValidationErrors{}},
+		{"blank pointer is nil", url.Values{"limit": {"  "}}, formModel{}, 1, ValidationErrors{}},
+		{"slice and skipped id", url.Values{"ids": {"1", "2"}, "id": {"5"}}, formModel{Ids: []uint{1, 2}}, 1, ValidationErrors{}},
+		{"empty values skipped", url.Values{"count": {""}, "password": {""}, "born": {" "}}, formModel{}, 0, ValidationErrors{}},
+		{"errors", url.Values{"count": {"x"}, "active": {"maybe"}, "born": {"17.05.2020"}, "ids": {"1", "-2"}},
+			formModel{}, 0, ValidationErrors{
+				"count":  "must be a whole number",
+				"active": "must be true or false",
+				"born":   "must be a date (2006-01-02)",
+				"ids":    "must be a positive whole number",
+			}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formModel{}
+			count, errs := FillForm(&got, tt.form)
+			if count != tt.count {
+				t.Errorf("count = %d, want %d", count, tt.count)
+			}
+			if !reflect.DeepEqual(errs, tt.errors) {
+				t.Errorf("errors = %v, want %v", errs, tt.errors)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("model = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFormTime(t *testing.T) {
+	tests := []struct {
+		value  string
+		layout string
+		want   time.Time
+		err    bool
+	}{
+		{"2020-05-17", "", time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC), false},
+		{"2020-05-17 08:09:10", "", time.Date(2020, 5, 17, 8, 9, 10, 0, time.UTC), false},
+		{"2020-05-17T08:09:10+02:00", "", time.Date(2020, 5, 17, 6, 9, 10, 0, time.UTC), false},
+		{"17/05/2020", "02/01/2006", time.Date(2020, 5, 17, 0, 0, 0, 0, time.UTC), false},
+		{"2020-05-17", "02/01/2006", time.Time{}, true},
+		{"yesterday", "", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFormTime(tt.value, tt.layout)
+		if (err != nil) != tt.err {
+			t.Errorf("parseFormTime(%q, %q) error = %v, want error %v", tt.value, tt.layout, err, tt.err)
+			continue
+		}
+		if !tt.err && !got.Equal(tt.want) {
+			t.Errorf("parseFormTime(%q, %q) = %v, want %v", tt.value, tt.layout, got, tt.want)
+		}
+		if !tt.err && got.Location() != time.UTC && tt.layout != "" {
+			t.Errorf("parseFormTime(%q, %q) location = %v, want UTC", tt.value, tt.layout, got.Location())
+		}
+	}
+}
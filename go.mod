@@ -6,11 +6,11 @@ require (
 	github.com/alexedwards/scs/v2 v2.5.1
 	github.com/denisenkom/go-mssqldb v0.11.0 // indirect
 	github.com/iancoleman/strcase v0.1.3
-	github.com/influxdata/influxdb-client-go/v2 v2.12.3 // indirect
+	github.com/influxdata/influxdb-client-go/v2 v2.12.3
 	github.com/jinzhu/gorm v1.9.16
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.2 // indirect
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible // indirect
-	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	gorm.io/gorm v1.22.4
 )
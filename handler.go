@@ -0,0 +1,140 @@
+package manago
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/julienschmidt/httprouter"
+)
+
+// HandlerFunc is typed alternative for controller methods, returned error is served as StatusError (see ErrorStatus),
+// ValidationErrors are set like with SetValidationErrors
+type HandlerFunc func(*Context) error
+
+type route struct {
+	method string
+	path   string
+	handle httprouter.Handle
+}
+
+// Route registers handle for method and path in router. Routes registered outside of controllers SetRoutes
+// are kept when router is rebuilt (ReloadStaticFS), SetRoutes registers its routes again on rebuild.
+func (man *Manager) Route(method string, path string, handle httprouter.Handle) {
+	if !man.makingRoutes {
+		man.routes = append(man.routes, route{method: method, path: path, handle: handle})
+	}
+	man.router.Handle(method, path, handle)
+}
+
+// HandleFunc prepares handle running fn and rendering tmplName template (unless redirect or error was set).
+// Name is used for middlewares in "controller.Method" form, eg. "users.Edit" runs middlewares set for
+// controller "users" and method "Edit".
+func (man *Manager) HandleFunc(name string, fn HandlerFunc, tmplName string) httprouter.Handle {
+	log.Printf("Manager HandleFunc: preparing: %s %s", name, tmplName)
+
+	return man.handleFunc(name, fn, func(w http.ResponseWriter, r *http.Request, ctx *Context) {
+		man.respondTemplate(w, r, ctx, tmplName, "func")
+	})
+}
+
+// HandleFuncJson prepares handle running fn and serving view content as json (see HandleFunc)
+func (man *Manager) HandleFuncJson(name string, fn HandlerFunc) httprouter.Handle {
+	log.Printf("Manager HandleFuncJson: preparing: %s", name)
+
+	return man.handleFunc(name, fn, func(w http.ResponseWriter, r *http.Request, ctx *Context) {
+		man.respondJson(w, r, ctx, "func_json")
+	})
+}
+
+func (man *Manager) handleFunc(name string, fn HandlerFunc, respond func(http.ResponseWriter, *http.Request, *Context)) httprouter.Handle {
+	ctrName, mtdName := name, ""
+	pos := strings.LastIndex(name, ".")
+	if pos > -1 {
+		ctrName, mtdName = name[:pos], name[pos+1:]
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		requestStarted := time.Now()
+
+		ctx := newContext(man, w, r, ctrName)
+
+		err := man.prepareController(ctx, w, r, ps, &requestStarted)
+		defer ctx.SessionRelease(w)
+		if err == nil {
+			err = ctx.setupContextDB()
+		}
+		if err != nil {
+			log.Print(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if man.verifyCsrf(ctrName, mtdName, ctx, r) {
+			man.runWithMiddleware(ctrName, mtdName, ctx, func() {
+				errFn := fn(ctx)
+				var fields ValidationErrors
+				if errors.As(errFn, &fields) {
+					ctx.SetValidationErrors(fields)
+				} else if errFn != nil {
+					se := ErrorStatus(errFn)
+					ctx.SetError(se.Code, se.Err, se.Msg)
+				}
+			})
+		}
+
+		respond(w, r, ctx)
+
+		man.Logger.LogExecutionTime(r.URL.Path, "func", time.Since(requestStarted))
+	}
+}
+
+// NewStatusError returns StatusError which can be returned from HandlerFunc, msg is shown to user (err message if not set)
+func NewStatusError(code int, err error, msg ...string) StatusError {
+	se := StatusError{Code: code, Err: err}
+	if len(msg) > 0 {
+		se.Msg = msg[0]
+		if err == nil {
+			se.Err = errors.New(msg[0])
+		}
+	} else if err != nil {
+		se.Msg = err.Error()
+	}
+
+	return se
+}
+
+func (se StatusError) Error() string {
+	if se.Err != nil {
+		return se.Err.Error()
+	}
+	return se.Msg
+}
+
+func (se StatusError) Unwrap() error {
+	return se.Err
+}
+
+// ErrorStatus maps error to StatusError: StatusError is kept, ValidationErrors give 422,
+// gorm.ErrRecordNotFound gives 404 and any other error 500
+func ErrorStatus(err error) StatusError {
+	var se StatusError
+	var sePtr *StatusError
+	var fields ValidationErrors
+
+	switch {
+	case errors.As(err, &se):
+		return se
+	case errors.As(err, &sePtr) && sePtr != nil:
+		return *sePtr
+	case errors.As(err, &fields):
+		return NewStatusError(http.StatusUnprocessableEntity, fields, "Validation failed")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NewStatusError(http.StatusNotFound, err, "Not found")
+	}
+
+	return NewStatusError(http.StatusInternalServerError, err)
+}
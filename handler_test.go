@@ -0,0 +1,259 @@
+package manago
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jinzhu/gorm"
+)
+
+func TestErrorStatus(t *testing.T) {
+	plain := errors.New("broken")
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+		msg  string
+	}{
+		{"status error", NewStatusError(403, nil, "Forbidden"), 403, "Forbidden"},
+		{"status error pointer", &StatusError{Code: 409, Err: plain, Msg: "Conflict"}, 409, "Conflict"},
+		{"wrapped status error", fmt.Errorf("saving: %w", NewStatusError(400, plain)), 400, "broken"},
+		{"validation errors", ValidationErrors{"name": "is required"}, 422, "Validation failed"},
+		{"record not found", fmt.Errorf("loading user: %w", gorm.ErrRecordNotFound), 404, "Not found"},
+		{"other error", plain, 500, "broken"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			se := ErrorStatus(tt.err)
+			if se.Code != tt.code || se.Msg != tt.msg {
+				t.Errorf("ErrorStatus = %d %q, want %d %q", se.Code, se.Msg, tt.code, tt.msg)
+			}
+			if se.Err == nil {
+				t.Error("ErrorStatus returned StatusError without Err")
+			}
+		})
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+	plain := errors.New("broken")
+
+	se := NewStatusError(500, plain, "Try again later")
+	if se.Error() != "broken" || se.Msg != "Try again later" || !errors.Is(se, plain) {
+		t.Errorf("NewStatusError with message = %+v", se)
+	}
+
+	se = NewStatusError(400, nil, "Bad input")
+	if se.Error() != "Bad input" {
+		t.Errorf("NewStatusError without error: Error() = %q", se.Error())
+	}
+}
+
+type HandlerTestController struct {
+	Controller
+}
+
+func (ctr *HandlerTestController) SetRoutes() {
+	ctr.Man.Route("GET", "/fn/:action", ctr.Man.HandleFuncJson("handler_test.Show", handlerTestFunc))
+	ctr.Man.Route("POST", "/fn/:action", ctr.Man.HandleFuncJson("handler_test.Update", handlerTestFunc))
+	ctr.Man.Route("GET", "/denied", ctr.Man.HandleFuncJson("handler_test.Denied", handlerTestFunc))
+}
+
+func handlerTestFunc(ctx *Context) error {
+	switch ctx.Param("action") {
+	case "forbidden":
+		return NewStatusError(403, nil, "Forbidden")
+	case "missing":
+		return fmt.Errorf("loading: %w", gorm.ErrRecordNotFound)
+	case "invalid":
+		return ValidationErrors{"name": "is required"}
+	case "broken":
+		return errors.New("broken")
+	case "db":
+		var one int
+		err := ctx.DB().Raw("SELECT 1").Row().Scan(&one)
+		if err != nil {
+			return err
+		}
+		ctx.SetCt("one", one)
+	case "session-set":
+		ctx.SessionSet("value", "stored")
+	case "session-get":
+		ctx.SetCt("value", ctx.SessionGet("value"))
+	}
+
+	ctx.SetCt("called", true)
+	return nil
+}
+
+// denyMiddleware stops request with 401
+type denyMiddleware struct{}
+
+func (dm *denyMiddleware) RunBefore(ctr Controlled, params map[string]string) bool {
+	ctr.SetError(http.StatusUnauthorized, errors.New("denied"), "Unauthorized")
+	return false
+}
+
+func (dm *denyMiddleware) RunAfter(ctr Controlled, params map[string]string) {}
+
+var handlerTestFS = fstest.MapFS{
+	"templates/base.gohtml": {Data: []byte(testBaseLayout)},
+	"web/app.css":           {Data: []byte("body {}")},
+}
+
+func newHandlerTestManager(t *testing.T, conf Config) *Manager {
+	conf.Db = DatabaseConfig{Server: "sqlite", SqlitePath: filepath.Join(t.TempDir(), "test.db")}
+	conf.TemplatesPath = "templates"
+	conf.WebStaticPath = "web"
+
+	man, err := NewFS(conf, handlerTestFS, []interface{}{HandlerTestController{}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { man.Dbc.Close() })
+
+	return man
+}
+
+func newHandlerTestClient(t *testing.T, man *Manager) (*httptest.Server, *http.Client) {
+	srv := httptest.NewServer(man.sessionManager.LoadAndSave(man.router))
+	t.Cleanup(srv.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, &http.Client{Jar: jar}
+}
+
+func handlerTestCall(t *testing.T, client *http.Client, method string, url string, header map[string]string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body := map[string]interface{}{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestHandleFunc(t *testing.T) {
+	man := newHandlerTestManager(t, Config{})
+	man.Mid.ControllerSet("handler_test", man.Mid.GetSet(&denyMiddleware{}), "Denied")
+	srv, client := newHandlerTestClient(t, man)
+
+	tests := []struct {
+		name   string
+		path   string
+		code   int
+		called bool
+	}{
+		{"ok", "/fn/ok", 200, true},
+		{"request db session", "/fn/db", 200, true},
+		{"status error", "/fn/forbidden", 403, false},
+		{"record not found", "/fn/missing", 404, false},
+		{"validation errors", "/fn/invalid", 422, false},
+		{"other error", "/fn/broken", 500, false},
+		{"middleware stops", "/denied", 401, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := handlerTestCall(t, client, "GET", srv.URL+tt.path, nil)
+			if code != tt.code {
+				t.Errorf("status = %d, want %d", code, tt.code)
+			}
+			if (body["called"] == true) != tt.called {
+				t.Errorf("response %v, want called %v", body, tt.called)
+			}
+		})
+	}
+
+	_, body := handlerTestCall(t, client, "GET", srv.URL+"/fn/db", nil)
+	if body["one"] != float64(1) {
+		t.Errorf("db query result = %v, want 1", body["one"])
+	}
+}
+
+func TestHandleFuncSession(t *testing.T) {
+	man := newHandlerTestManager(t, Config{})
+	srv, client := newHandlerTestClient(t, man)
+
+	handlerTestCall(t, client, "GET", srv.URL+"/fn/session-set", nil)
+	_, body := handlerTestCall(t, client, "GET", srv.URL+"/fn/session-get", nil)
+	if body["value"] != "stored" {
+		t.Errorf("session value = %v, want stored", body["value"])
+	}
+}
+
+func TestHandleFuncCsrf(t *testing.T) {
+	man := newHandlerTestManager(t, Config{Csrf: true})
+	srv, client := newHandlerTestClient(t, man)
+
+	_, body := handlerTestCall(t, client, "GET", srv.URL+"/fn/ok", nil)
+	token, _ := body["CsrfToken"].(string)
+	if len(token) == 0 {
+		t.Fatalf("GET response without CsrfToken: %v", body)
+	}
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		code   int
+	}{
+		{"missing token", nil, 403},
+		{"wrong token", map[string]string{CsrfHeaderName: "wrong"}, 403},
+		{"valid token", map[string]string{CsrfHeaderName: token}, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _ := handlerTestCall(t, client, "POST", srv.URL+"/fn/ok", tt.header)
+			if code != tt.code {
+				t.Errorf("status = %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestRouteFromSetRoutesSurvivesReload(t *testing.T) {
+	man := newHandlerTestManager(t, Config{})
+	man.Route("GET", "/extra", man.HandleFuncJson("extra.Show", handlerTestFunc))
+
+	for ix := 0; ix < 2; ix++ {
+		err := man.ReloadStaticFS(handlerTestFS)
+		if err != nil {
+			t.Fatalf("ReloadStaticFS failed: %v", err)
+		}
+	}
+
+	if len(man.routes) != 1 {
+		t.Errorf("kept routes = %d, want only route registered outside SetRoutes", len(man.routes))
+	}
+
+	srv, client := newHandlerTestClient(t, man)
+	for _, path := range []string{"/fn/ok", "/extra"} {
+		code, _ := handlerTestCall(t, client, "GET", srv.URL+path, nil)
+		if code != 200 {
+			t.Errorf("GET %s after reload = %d, want 200", path, code)
+		}
+	}
+}
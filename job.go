@@ -0,0 +1,336 @@
+package manago
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	// JobDead is set after MaxAttempts failed runs, job is kept for inspection and RetryJob
+	JobDead = "dead"
+)
+
+const (
+	defaultJobMaxAttempts = 5
+	defaultJobPoll        = 5 * time.Second
+	defaultJobLock        = 5 * time.Minute
+	jobBackoffBase        = 10 * time.Second
+	jobBackoffMax         = time.Hour
+)
+
+var errJobLockLost = errors.New("job lock lost, job was claimed again by other worker")
+
+// Job is queued call of controller method with signature func(*Job) error, table is created by Manager Migrate
+type Job struct {
+	ID          uint
+	Controller  string `gorm:"not null"`
+	Method      string `gorm:"not null"`
+	Payload     string `gorm:"type:text"`
+	Status      string `gorm:"index:idx_manago_job_claim;not null"`
+	Attempts    uint
+	MaxAttempts uint
+	RunAt       time.Time `gorm:"index:idx_manago_job_claim"`
+	LockedBy    string
+	LockedUntil time.Time
+	LastError   string `gorm:"type:text"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (Job) TableName() string {
+	return "manago_jobs"
+}
+
+// Decode unmarshals json payload into target
+func (job *Job) Decode(target interface{}) error {
+	return json.Unmarshal([]byte(job.Payload), target)
+}
+
+// JobOptions for Enqueue: job runs at RunAt or after Delay (immediately when both are empty),
+// MaxAttempts defaults to 5
+type JobOptions struct {
+	RunAt       time.Time
+	Delay       time.Duration
+	MaxAttempts uint
+}
+
+var jobHandlerType = reflect.TypeOf(func(*Job) error { return nil })
+
+// Enqueue stores job for handler in "controller.Method" form, payload is saved as json.
+// Job is inserted with db (eg. inside transaction), with new session when db is nil.
+func (man *Manager) Enqueue(db *gorm.DB, handler string, payload interface{}, opts ...JobOptions) (*Job, error) {
+	pos := strings.LastIndex(handler, ".")
+	if pos < 0 {
+		return nil, fmt.Errorf("Manager Enqueue: handler [%s] expected in controller.Method form", handler)
+	}
+	job := &Job{Controller: handler[:pos], Method: handler[pos+1:], Status: JobPending, MaxAttempts: defaultJobMaxAttempts, RunAt: time.Now()}
+
+	typ, isOk := man.controllersReflected[job.Controller]
+	if !isOk {
+		return nil, fmt.Errorf("Manager Enqueue: controller [%s] not found", job.Controller)
+	}
+	method, isOk := reflect.PtrTo(typ).MethodByName(job.Method)
+	if !isOk || method.Type.NumIn() != 2 || method.Type.In(1) != jobHandlerType.In(0) || method.Type.NumOut() != 1 || method.Type.Out(0) != jobHandlerType.Out(0) {
+		return nil, fmt.Errorf("Manager Enqueue: method [%s] of [%s] not found or is not func(*Job) error", job.Method, job.Controller)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("Manager Enqueue: encoding payload failed: %w", err)
+	}
+	job.Payload = string(encoded)
+
+	if len(opts) > 0 {
+		if !opts[0].RunAt.IsZero() {
+			job.RunAt = opts[0].RunAt
+		}
+		job.RunAt = job.RunAt.Add(opts[0].Delay)
+		if opts[0].MaxAttempts > 0 {
+			job.MaxAttempts = opts[0].MaxAttempts
+		}
+	}
+	job.RunAt = job.RunAt.UTC()
+
+	if db == nil {
+		db, err = man.Dbc.Open()
+		if err != nil {
+			return nil, fmt.Errorf("Manager Enqueue: %w", err)
+		}
+	}
+
+	err = db.Create(job).Error
+	if err != nil {
+		return nil, fmt.Errorf("Manager Enqueue: saving job failed: %w", err)
+	}
+
+	return job, nil
+}
+
+// RetryJob moves dead (or failed pending) job back to queue with attempts reset
+func (man *Manager) RetryJob(id uint) error {
+	db, err := man.Dbc.Open()
+	if err != nil {
+		return err
+	}
+
+	res := db.Model(&Job{}).Where("id = ? AND status IN (?)", id, []string{JobDead, JobPending}).
+		Updates(map[string]interface{}{"status": JobPending, "attempts": 0, "run_at": time.Now().UTC()})
+	if res.Error != nil {
+		return fmt.Errorf("Manager RetryJob: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("Manager RetryJob: job %d not found or not dead", id)
+	}
+
+	return nil
+}
+
+// jobWorker runs claimed jobs one by one, polling queue when it is empty
+func (man *Manager) jobWorker(stop <-chan struct{}) {
+	poll := defaultJobPoll
+	if man.Config.Jobs.PollSeconds > 0 {
+		poll = time.Duration(man.Config.Jobs.PollSeconds) * time.Second
+	}
+
+	for {
+		job, err := man.claimJob()
+		if err != nil {
+			log.Printf("Manager jobWorker: %v", err)
+		}
+
+		if job != nil {
+			man.runJob(job)
+
+			select {
+			case <-stop:
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(poll):
+		}
+	}
+}
+
+// jobLock returns time job stays locked by worker without renewing
+func (man *Manager) jobLock() time.Duration {
+	if man.Config.Jobs.LockSeconds > 0 {
+		return time.Duration(man.Config.Jobs.LockSeconds) * time.Second
+	}
+
+	return defaultJobLock
+}
+
+// jobClaimOwner returns owner token of single claim (instance name with random suffix), so worker can tell
+// its claim from later claim of the same job
+func (man *Manager) jobClaimOwner() string {
+	random := make([]byte, 8)
+	rand.Read(random)
+
+	return man.jobOwner + "/" + hex.EncodeToString(random)
+}
+
+// claimJob takes oldest due job, or job left running by instance which lock expired,
+// conditional update makes sure only one worker gets it
+func (man *Manager) claimJob() (*Job, error) {
+	db, err := man.Dbc.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := man.jobLock()
+	for {
+		now := time.Now().UTC()
+		claimable := db.Model(&Job{}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)", JobPending, now, JobRunning, now)
+
+		job := &Job{}
+		err = claimable.Order("run_at").First(job).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("claimJob: %w", err)
+		}
+
+		res := claimable.Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"status":       JobRunning,
+			"locked_by":    man.jobClaimOwner(),
+			"locked_until": now.Add(lock),
+			"attempts":     gorm.Expr("attempts + 1"),
+		})
+		if res.Error != nil {
+			return nil, fmt.Errorf("claimJob: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			// other worker was faster
+			continue
+		}
+
+		return job, db.First(job, job.ID).Error
+	}
+}
+
+// runJob calls job handler (renewing job lock while it runs) and saves result,
+// failed job is retried with exponential backoff until MaxAttempts
+func (man *Manager) runJob(job *Job) {
+	release := man.holdJob(job)
+	err := man.callJob(job)
+	release()
+
+	updates := map[string]interface{}{"locked_by": "", "locked_until": time.Time{}, "last_error": ""}
+	switch {
+	case err == nil:
+		updates["status"] = JobDone
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = JobDead
+		updates["last_error"] = err.Error()
+	default:
+		updates["status"] = JobPending
+		updates["last_error"] = err.Error()
+		updates["run_at"] = time.Now().UTC().Add(jobBackoff(job.Attempts))
+		log.Printf("Manager runJob: job %d %s.%s attempt %d failed, retrying: %v", job.ID, job.Controller, job.Method, job.Attempts, err)
+	}
+
+	errDb := man.updateClaimedJob(job, updates)
+	if errDb != nil {
+		errDb = fmt.Errorf("Manager runJob: saving job %d %s.%s result failed: %w", job.ID, job.Controller, job.Method, errDb)
+		log.Print(errDb)
+		man.Logger.LogError("jobs/"+job.Controller+"."+job.Method, "job", errDb, 500)
+		return
+	}
+
+	if updates["status"] == JobDead {
+		man.reportJobFailure(job, err)
+	}
+}
+
+// holdJob renews job lock until returned release func is called (see renewLease)
+func (man *Manager) holdJob(job *Job) (release func()) {
+	lock := man.jobLock()
+
+	return renewLease(fmt.Sprintf("Manager holdJob %d", job.ID), lock, func() error {
+		return man.updateClaimedJob(job, map[string]interface{}{"locked_until": time.Now().UTC().Add(lock)})
+	}, errJobLockLost)
+}
+
+// updateClaimedJob updates job only when it is still claimed by worker which runs it
+func (man *Manager) updateClaimedJob(job *Job, updates map[string]interface{}) error {
+	db, err := man.Dbc.Open()
+	if err != nil {
+		return err
+	}
+
+	res := db.Model(&Job{}).Where("id = ? AND locked_by = ?", job.ID, job.LockedBy).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errJobLockLost
+	}
+
+	return nil
+}
+
+func (man *Manager) callJob(job *Job) error {
+	method, ctr, err := man.controllerMethod(job.Controller, job.Method)
+	if err != nil {
+		return err
+	}
+
+	err, stack := safeCall(method, reflect.ValueOf(job))
+	if len(stack) > 0 {
+		log.Printf("Manager callJob: job %d %s.%s %v\n%s", job.ID, job.Controller, job.Method, err, stack)
+	}
+	if err == nil && ctr.IsError() {
+		err = ctr.GetError().Err
+		if err == nil {
+			err = errors.New(ctr.GetError().Msg)
+		}
+	}
+
+	return err
+}
+
+// jobBackoff doubles delay with every attempt starting from 10s, up to 1h
+func jobBackoff(attempts uint) time.Duration {
+	delay := jobBackoffBase
+	for ix := uint(1); ix < attempts && delay < jobBackoffMax; ix++ {
+		delay *= 2
+	}
+	if delay > jobBackoffMax {
+		delay = jobBackoffMax
+	}
+
+	return delay
+}
+
+func (man *Manager) reportJobFailure(job *Job, err error) {
+	log.Printf("Manager job %d %s.%s is dead after %d attempts: %v", job.ID, job.Controller, job.Method, job.Attempts, err)
+
+	man.Logger.LogError("jobs/"+job.Controller+"."+job.Method, "job", err, 500)
+
+	if man.Messaging != nil {
+		errSend := man.Messaging.QuickSend(fmt.Sprintf("%s: job %d %s.%s failed %d times: %v", man.Config.Name, job.ID, job.Controller, job.Method, job.Attempts, err))
+		if errSend != nil {
+			log.Printf("Manager reportJobFailure: sending message failed: %v", errSend)
+		}
+	}
+}
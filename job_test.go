@@ -0,0 +1,213 @@
+package manago
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hubertat/manago/logging"
+)
+
+type JobTestController struct {
+	Controller
+}
+
+type jobTestPayload struct {
+	Fail  bool
+	Sleep time.Duration
+}
+
+var jobTestStarted = make(chan struct{}, 1)
+
+func (ctr *JobTestController) Process(job *Job) error {
+	payload := jobTestPayload{}
+	err := job.Decode(&payload)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case jobTestStarted <- struct{}{}:
+	default:
+	}
+	time.Sleep(payload.Sleep)
+
+	if payload.Fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (ctr *JobTestController) Wrong(payload string) error {
+	return nil
+}
+
+func newJobTestManager(t *testing.T) *Manager {
+	return &Manager{
+		Dbc:                  newTestDb(t),
+		Logger:               &logging.NilLogger{},
+		controllersReflected: map[string]reflect.Type{"job_test": reflect.TypeOf(JobTestController{})},
+		jobOwner:             instanceName(),
+	}
+}
+
+func loadJob(t *testing.T, man *Manager, id uint) Job {
+	job := Job{}
+	err := man.Dbc.DB.First(&job, id).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestJobBackoff(t *testing.T) {
+	tests := []struct {
+		attempts uint
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		got := jobBackoff(tt.attempts)
+		if got != tt.want {
+			t.Errorf("jobBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	man := newJobTestManager(t)
+
+	for _, handler := range []string{"job_test", "missing.Process", "job_test.Missing", "job_test.Wrong"} {
+		_, err := man.Enqueue(nil, handler, nil)
+		if err == nil {
+			t.Errorf("Enqueue(%q) accepted wrong handler", handler)
+		}
+	}
+
+	runAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	job, err := man.Enqueue(nil, "job_test.Process", jobTestPayload{Fail: true}, JobOptions{RunAt: runAt, Delay: time.Minute, MaxAttempts: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := loadJob(t, man, job.ID)
+	if saved.Status != JobPending || saved.MaxAttempts != 2 || !saved.RunAt.Equal(runAt.Add(time.Minute)) || saved.Payload != `{"Fail":true,"Sleep":0}` {
+		t.Errorf("saved job = %+v", saved)
+	}
+
+	claimed, err := man.claimJob()
+	if err != nil || claimed != nil {
+		t.Errorf("claimJob took job scheduled in future: %+v (%v)", claimed, err)
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     jobTestPayload
+		maxAttempts uint
+		status      string
+		lastError   string
+	}{
+		{"done", jobTestPayload{}, 0, JobDone, ""},
+		{"retried", jobTestPayload{Fail: true}, 0, JobPending, "failed"},
+		{"dead", jobTestPayload{Fail: true}, 1, JobDead, "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			man := newJobTestManager(t)
+			job, err := man.Enqueue(nil, "job_test.Process", tt.payload, JobOptions{MaxAttempts: tt.maxAttempts})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claimed, err := man.claimJob()
+			if err != nil || claimed == nil || claimed.ID != job.ID {
+				t.Fatalf("claimJob = %+v (%v)", claimed, err)
+			}
+			if claimed.Status != JobRunning || claimed.Attempts != 1 || len(claimed.LockedBy) == 0 {
+				t.Errorf("claimed job = %+v", claimed)
+			}
+
+			man.runJob(claimed)
+
+			saved := loadJob(t, man, job.ID)
+			if saved.Status != tt.status || saved.LastError != tt.lastError || len(saved.LockedBy) > 0 {
+				t.Errorf("job after run = %+v, want status %s", saved, tt.status)
+			}
+			if tt.status == JobPending && saved.RunAt.Before(time.Now().Add(5*time.Second)) {
+				t.Errorf("retried job runs at %v, without backoff", saved.RunAt)
+			}
+		})
+	}
+}
+
+func TestRunJobLockLost(t *testing.T) {
+	man := newJobTestManager(t)
+	job, err := man.Enqueue(nil, "job_test.Process", jobTestPayload{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := man.claimJob()
+	if err != nil || claimed == nil {
+		t.Fatalf("claimJob = %+v (%v)", claimed, err)
+	}
+
+	// job claimed again by other worker of the same instance
+	other := man.jobClaimOwner()
+	err = man.Dbc.DB.Model(&Job{}).Where("id = ?", job.ID).Update("locked_by", other).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	man.runJob(claimed)
+
+	saved := loadJob(t, man, job.ID)
+	if saved.Status != JobRunning || saved.LockedBy != other {
+		t.Errorf("result of worker which lost lock was saved: %+v", saved)
+	}
+}
+
+func TestRunJobRenewsLock(t *testing.T) {
+	man := newJobTestManager(t)
+	man.Config.Jobs.LockSeconds = 1
+	job, err := man.Enqueue(nil, "job_test.Process", jobTestPayload{Sleep: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := man.claimJob()
+	if err != nil || claimed == nil {
+		t.Fatalf("claimJob = %+v (%v)", claimed, err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		man.runJob(claimed)
+		close(done)
+	}()
+	<-jobTestStarted
+
+	time.Sleep(1200 * time.Millisecond)
+	again, err := man.claimJob()
+	if err != nil || again != nil {
+		t.Errorf("running job claimed again: %+v (%v)", again, err)
+	}
+
+	<-done
+	saved := loadJob(t, man, job.ID)
+	if saved.Status != JobDone || saved.Attempts != 1 {
+		t.Errorf("job after run = %+v", saved)
+	}
+}
@@ -0,0 +1,192 @@
+package manago
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"text/template/parse"
+)
+
+const (
+	// DefaultLayout wraps pages without layout set in controller or front-matter
+	DefaultLayout = "base.gohtml"
+	// NoLayout renders only page "content" template, eg. for htmx fragments
+	NoLayout = "none"
+
+	maxLayoutDepth = 10
+)
+
+// layoutFrontMatter matches first line of page or layout selecting its layout, eg. {{/* layout: print.gohtml */}}
+var layoutFrontMatter = regexp.MustCompile(`^\{\{-?\s*/\*\s*layout:\s*([^\s*]+)\s*\*/\s*-?\}\}`)
+
+// readLayout returns layout set in front-matter of file, empty when not set
+func readLayout(fsys fs.FS, filePath string) (string, error) {
+	file, err := fsys.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return "", scanner.Err()
+	}
+
+	match := layoutFrontMatter.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+	if match == nil {
+		return "", nil
+	}
+
+	return layoutName(match[1]), nil
+}
+
+// layoutName accepts layout with or without .gohtml extension
+func layoutName(name string) string {
+	if len(name) == 0 || strings.EqualFold(name, NoLayout) || len(path.Ext(name)) > 0 {
+		return name
+	}
+
+	return name + ".gohtml"
+}
+
+// layoutChain returns layouts wrapping page, from innermost to outermost (which is executed)
+func (vs *ViewSet) layoutChain(layout string) ([]string, error) {
+	chain := []string{}
+
+	for len(layout) > 0 {
+		if len(chain) == maxLayoutDepth {
+			return nil, fmt.Errorf("layout chain %v too deep (cycle?)", chain)
+		}
+		chain = append(chain, layout)
+		layout = vs.layoutParents[layout]
+	}
+
+	return chain, nil
+}
+
+// variant returns template for page rendered in layout, built from clone of never executed page template
+// (html/template can not be cloned after execution) and cached until next Load
+func (vs *ViewSet) variant(name string, layout string) (*template.Template, string, error) {
+	master, found := vs.ts[name]
+	if !found {
+		log.Printf("template %s not present in the ts map, using default", name)
+		master, found = vs.ts["default"]
+		if !found {
+			return nil, "", fmt.Errorf("template %s not found and no default template", name)
+		}
+		name = "default"
+	}
+
+	layout = layoutName(layout)
+	if len(layout) == 0 {
+		layout = vs.pageLayouts[name]
+	}
+	if len(layout) == 0 {
+		layout = DefaultLayout
+	}
+
+	if strings.EqualFold(layout, NoLayout) {
+		layout = NoLayout
+	}
+
+	key := name + "|" + layout
+	vs.variantsLock.Lock()
+	defer vs.variantsLock.Unlock()
+
+	cached, found := vs.variants[key]
+	if found {
+		return cached.t, cached.entry, nil
+	}
+
+	t, err := master.Clone()
+	if err != nil {
+		return nil, "", fmt.Errorf("cloning %s failed: %w", name, err)
+	}
+
+	entry := "content"
+	if layout != NoLayout {
+		entry, err = vs.wrapInLayouts(t, layout)
+		if err != nil {
+			return nil, "", fmt.Errorf("template %s in layout %s: %w", name, layout, err)
+		}
+	}
+
+	vs.variants[key] = templateVariant{t: t, entry: entry}
+
+	return t, entry, nil
+}
+
+// wrapInLayouts installs nested layouts into t, so outermost layout (returned entry) renders them all:
+// every inner layout becomes "content" of its parent and its own "content" calls are renamed to "content@layout"
+func (vs *ViewSet) wrapInLayouts(t *template.Template, layout string) (entry string, err error) {
+	chain, err := vs.layoutChain(layout)
+	if err != nil {
+		return
+	}
+
+	for _, name := range chain {
+		if t.Lookup(name) == nil {
+			err = fmt.Errorf("layout %s not found", name)
+			return
+		}
+	}
+
+	content := t.Lookup("content")
+	if content == nil || content.Tree == nil {
+		err = fmt.Errorf("content template not defined")
+		return
+	}
+
+	current := content.Tree.Copy()
+	for _, name := range chain[:len(chain)-1] {
+		innerName := "content@" + name
+		_, err = t.AddParseTree(innerName, current)
+		if err != nil {
+			return
+		}
+
+		current = t.Lookup(name).Tree.Copy()
+		renameTemplateCalls(current.Root, "content", innerName)
+	}
+
+	_, err = t.AddParseTree("content", current)
+	entry = chain[len(chain)-1]
+
+	return
+}
+
+// renameTemplateCalls changes {{template from ...}} calls in tree to {{template to ...}}
+func renameTemplateCalls(node parse.Node, from string, to string) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			renameTemplateCalls(child, from, to)
+		}
+	case *parse.TemplateNode:
+		if node.Name == from {
+			node.Name = to
+		}
+	case *parse.IfNode:
+		renameTemplateCalls(node.List, from, to)
+		renameTemplateCalls(node.ElseList, from, to)
+	case *parse.RangeNode:
+		renameTemplateCalls(node.List, from, to)
+		renameTemplateCalls(node.ElseList, from, to)
+	case *parse.WithNode:
+		renameTemplateCalls(node.List, from, to)
+		renameTemplateCalls(node.ElseList, from, to)
+	}
+}
+
+type templateVariant struct {
+	t     *template.Template
+	entry string
+}
@@ -0,0 +1,131 @@
+package manago
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no front-matter", `{{define "content"}}x{{end}}`, ""},
+		{"layout with extension", "{{/* layout: print.gohtml */}}\n{{define \"content\"}}x{{end}}", "print.gohtml"},
+		{"layout without extension", "{{/* layout: print */}}\n", "print.gohtml"},
+		{"trim markers", "{{- /* layout: print */ -}}\n", "print.gohtml"},
+		{"no layout", "{{/* layout: none */}}\n", NoLayout},
+		{"not on first line", "\n{{/* layout: print */}}\n", ""},
+		{"other comment", "{{/* page title */}}\n", ""},
+		{"empty file", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"page.html": {Data: []byte(tt.content)}}
+			got, err := readLayout(fsys, "page.html")
+			if err != nil || got != tt.want {
+				t.Errorf("readLayout = %q (%v), want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	_, err := readLayout(fstest.MapFS{}, "missing.html")
+	if err == nil {
+		t.Errorf("readLayout of missing file should fail")
+	}
+}
+
+func TestLayoutName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", ""},
+		{"print", "print.gohtml"},
+		{"print.gohtml", "print.gohtml"},
+		{"print.html", "print.html"},
+		{"none", "none"},
+		{"NONE", "NONE"},
+	}
+
+	for _, tt := range tests {
+		got := layoutName(tt.name)
+		if got != tt.want {
+			t.Errorf("layoutName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFireTemplateLayouts(t *testing.T) {
+	vs, err := newTestViews(t, map[string]string{
+		"base.gohtml":    testBaseLayout,
+		"print.gohtml":   `<print>{{template "content" .}}</print>`,
+		"admin.gohtml":   "{{- /* layout: base */ -}}\n<admin>{{template \"content\" .}}</admin>",
+		"section.gohtml": "{{- /* layout: admin */ -}}\n<section>{{if .Name}}{{template \"content\" .}}{{end}}</section>",
+		"home.html":      `{{define "content"}}Hi {{.Name}}{{end}}`,
+		"report.html":    "{{/* layout: print */}}\n{{define \"content\"}}Report{{end}}",
+		"users.html":     "{{/* layout: admin */}}\n{{define \"content\"}}Users{{end}}",
+		"settings.html":  "{{/* layout: section */}}\n{{define \"content\"}}Settings {{.Name}}{{end}}",
+		"fragment.html":  "{{/* layout: none */}}\n{{define \"content\"}}Row{{end}}",
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		page   string
+		layout string
+		body   string
+		err    bool
+	}{
+		{"default layout", "home", "", "<main>Hi John</main>", false},
+		{"front-matter layout", "report", "", "<print>Report</print>", false},
+		{"nested layout", "users", "", "<main><admin>Users</admin></main>", false},
+		{"layout nested twice", "settings", "", "<main><admin><section>Settings John</section></admin></main>", false},
+		{"front-matter no layout", "fragment", "", "Row", false},
+		{"explicit layout", "home", "print", "<print>Hi John</print>", false},
+		{"explicit layout over front-matter", "report", "base.gohtml", "<main>Report</main>", false},
+		{"explicit nested layout", "home", "admin", "<main><admin>Hi John</admin></main>", false},
+		{"explicit no layout", "users", NoLayout, "Users", false},
+		{"explicit no layout ignores case", "home", "None", "Hi John", false},
+		{"missing layout", "home", "missing", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := renderTemplate(vs, tt.page, tt.layout, map[string]interface{}{"Name": "John"})
+			if (err != nil) != tt.err {
+				t.Fatalf("FireTemplateLayout error = %v, want error %v", err, tt.err)
+			}
+			if !tt.err && w.Body.String() != tt.body {
+				t.Errorf("FireTemplateLayout = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestFireTemplateLayoutCycle(t *testing.T) {
+	vs, err := newTestViews(t, map[string]string{
+		"base.gohtml":  testBaseLayout,
+		"one.gohtml":   "{{/* layout: two */}}\n<one>{{template \"content\" .}}</one>",
+		"two.gohtml":   "{{/* layout: one */}}\n<two>{{template \"content\" .}}</two>",
+		"page.html":    "{{/* layout: one */}}\n{{define \"content\"}}Page{{end}}",
+		"regular.html": `{{define "content"}}Regular{{end}}`,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = renderTemplate(vs, "page", "", map[string]interface{}{})
+	if err == nil {
+		t.Errorf("page in layout cycle should fail")
+	}
+
+	w, err := renderTemplate(vs, "regular", "", map[string]interface{}{})
+	if err != nil || w.Body.String() != "<main>Regular</main>" {
+		t.Errorf("page outside cycle = %q (%v), want %q", w.Body.String(), err, "<main>Regular</main>")
+	}
+}
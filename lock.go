@@ -0,0 +1,214 @@
+package manago
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	defaultExclusiveTimeout = 10 * time.Second
+	dbLockPollInterval      = 100 * time.Millisecond
+)
+
+var ErrLockTimeout = errors.New("waiting for lock timed out")
+
+// Locker provides named exclusive locks, returned unlock must be called to release lock
+type Locker interface {
+	Lock(ctx context.Context, name string) (unlock func(), err error)
+}
+
+// MemoryLocker locks within current process
+type MemoryLocker struct {
+	locks map[string]chan struct{}
+	mu    sync.Mutex
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]chan struct{})}
+}
+
+func (ml *MemoryLocker) Lock(ctx context.Context, name string) (unlock func(), err error) {
+	ml.mu.Lock()
+	lock, present := ml.locks[name]
+	if !present {
+		lock = make(chan struct{}, 1)
+		ml.locks[name] = lock
+	}
+	ml.mu.Unlock()
+
+	select {
+	case lock <- struct{}{}:
+		unlock = func() { <-lock }
+		return
+	case <-ctx.Done():
+		err = ErrLockTimeout
+		return
+	}
+}
+
+// DbLocker uses database advisory locks (postgres pg_try_advisory_lock polled until ctx is done, mssql sp_getapplock),
+// so lock is shared between all app instances using the same database. Locks are held by connection session,
+// connection is discarded (not returned to pool) when locking or unlocking fails.
+type DbLocker struct {
+	Dbc *Db
+}
+
+func (dl *DbLocker) Lock(ctx context.Context, name string) (unlock func(), err error) {
+	if dl.Dbc.DB == nil {
+		err = fmt.Errorf("DbLocker Lock: database not connected")
+		return
+	}
+
+	conn, err := dl.Dbc.DB.DB().Conn(ctx)
+	if err != nil {
+		err = lockError("DbLocker Lock: getting connection failed", err)
+		return
+	}
+
+	switch dl.Dbc.config.Server {
+	case "postgres":
+		key := lockKey(name)
+		err = pollPgLock(ctx, conn, key)
+		if err != nil {
+			discardConn(conn)
+			err = lockError("DbLocker Lock: pg_try_advisory_lock failed", err)
+			return
+		}
+		unlock = func() {
+			var unlocked bool
+			errUnlock := conn.QueryRowContext(context.Background(), "SELECT pg_advisory_unlock($1)", key).Scan(&unlocked)
+			if errUnlock != nil || !unlocked {
+				log.Printf("DbLocker: releasing lock %s failed (unlocked: %v): %v", name, unlocked, errUnlock)
+				discardConn(conn)
+				return
+			}
+			conn.Close()
+		}
+
+	case "mssql":
+		timeoutMs := -1
+		deadline, hasDeadline := ctx.Deadline()
+		if hasDeadline {
+			timeoutMs = int(time.Until(deadline).Milliseconds())
+		}
+		var result int
+		err = conn.QueryRowContext(ctx, `DECLARE @res INT; EXEC @res = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = @p2; SELECT @res`,
+			name, timeoutMs).Scan(&result)
+		if err == nil && result < 0 {
+			err = ErrLockTimeout
+		}
+		if err != nil {
+			discardConn(conn)
+			err = lockError("DbLocker Lock: sp_getapplock failed", err)
+			return
+		}
+		unlock = func() {
+			_, errUnlock := conn.ExecContext(context.Background(), "EXEC sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", name)
+			if errUnlock != nil {
+				log.Printf("DbLocker: releasing lock %s failed: %v", name, errUnlock)
+				discardConn(conn)
+				return
+			}
+			conn.Close()
+		}
+
+	default:
+		conn.Close()
+		err = fmt.Errorf("DbLocker Lock: advisory locks not supported for %s database", dl.Dbc.config.Server)
+	}
+
+	return
+}
+
+// pollPgLock tries to take advisory lock until it succeeds or ctx is done, so cancelled call never leaves lock taken
+func pollPgLock(ctx context.Context, conn *sql.Conn, key int64) error {
+	ticker := time.NewTicker(dbLockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var locked bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked)
+		if err != nil || locked {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ErrLockTimeout
+		}
+	}
+}
+
+// discardConn closes connection instead of returning it to pool, releasing session locks it might still hold
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(driverConn interface{}) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
+
+func lockError(msg string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrLockTimeout) {
+		return fmt.Errorf("%s: %w", msg, ErrLockTimeout)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// newLocker selects locker by Config ExclusiveLocks ("db" for DbLocker with postgres and mssql, memory otherwise)
+func (man *Manager) newLocker() Locker {
+	if strings.EqualFold(man.Config.ExclusiveLocks, "db") {
+		switch man.Config.Db.Server {
+		case "postgres", "mssql":
+			return &DbLocker{Dbc: man.Dbc}
+		}
+		log.Printf("Manager: db exclusive locks not supported for %s, using memory locks", man.Config.Db.Server)
+	}
+
+	return NewMemoryLocker()
+}
+
+// Exclusive wraps handle so only one request at a time runs it for lockName (eg. for generating next document
+// number). Requests waiting longer than Config ExclusiveTimeoutSeconds (10s default) get 503.
+func (man *Manager) Exclusive(lockName string, handle httprouter.Handle) httprouter.Handle {
+	timeout := defaultExclusiveTimeout
+	if man.Config.ExclusiveTimeoutSeconds > 0 {
+		timeout = time.Duration(man.Config.ExclusiveTimeoutSeconds) * time.Second
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		unlock, err := man.Locks.Lock(ctx, lockName)
+		cancel()
+		if err != nil {
+			log.Printf("Manager Exclusive %s: %v", lockName, err)
+			man.Logger.LogError(r.URL.Path, "exclusive", err, http.StatusServiceUnavailable)
+			if errors.Is(err, ErrLockTimeout) {
+				http.Error(w, "Server busy, please try again", http.StatusServiceUnavailable)
+			} else {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			}
+			return
+		}
+		defer unlock()
+
+		handle(w, r, ps)
+	}
+}
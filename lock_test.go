@@ -0,0 +1,80 @@
+package manago
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hubertat/manago/logging"
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	ml := NewMemoryLocker()
+
+	unlock, err := ml.Lock(context.Background(), "invoice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	_, err = ml.Lock(ctx, "invoice")
+	cancel()
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("second lock error = %v, want ErrLockTimeout", err)
+	}
+
+	unlockOther, err := ml.Lock(context.Background(), "order")
+	if err != nil {
+		t.Fatalf("lock with other name failed: %v", err)
+	}
+	unlockOther()
+
+	unlock()
+	unlock, err = ml.Lock(context.Background(), "invoice")
+	if err != nil {
+		t.Fatalf("lock after unlock failed: %v", err)
+	}
+	unlock()
+}
+
+func TestExclusive(t *testing.T) {
+	man := &Manager{Config: Config{ExclusiveTimeoutSeconds: 1}, Locks: NewMemoryLocker(), Logger: &logging.NilLogger{}}
+	handle := man.Exclusive("invoice", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	handle(w, httptest.NewRequest("POST", "/invoices", nil), nil)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("free lock: status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	unlock, _ := man.Locks.Lock(context.Background(), "invoice")
+	defer unlock()
+
+	w = httptest.NewRecorder()
+	handle(w, httptest.NewRequest("POST", "/invoices", nil), nil)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("taken lock: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestDiscardConn(t *testing.T) {
+	dbc := newTestDb(t)
+	pool := dbc.DB.DB()
+
+	conn, err := pool.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	discardConn(conn)
+
+	stats := pool.Stats()
+	if stats.OpenConnections != 0 || stats.Idle != 0 {
+		t.Errorf("discarded connection kept in pool: %d open, %d idle", stats.OpenConnections, stats.Idle)
+	}
+}
@@ -1,13 +1,20 @@
 package manago
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"reflect"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
@@ -18,6 +25,8 @@ import (
 
 const defaultSessionLifetime = 24 * time.Hour
 const httpServerTimeout = 15 * time.Second
+const shutdownTimeout = 30 * time.Second
+const dbStatsInterval = time.Minute
 
 type Manager struct {
 	sessionManager *scs.SessionManager
@@ -26,29 +35,52 @@ type Manager struct {
 	controllersReflected map[string]reflect.Type
 	modelsReflected      map[string]reflect.Type
 
-	Config     Config
-	Views      *ViewSet
-	Dbc        *Db
-	Mid        *MiddlewareManager
-	Clients    map[string]Client
-	StaticFsys fs.FS
-	Messaging  Messenger
-	CronTasks  []Cron
-	Logger     logging.Logger
+	Config        Config
+	Views         *ViewSet
+	Dbc           *Db
+	Mid           *MiddlewareManager
+	Authenticator *Authenticator
+	Locks         Locker
+	Clients       map[string]Client
+	StaticFsys    fs.FS
+	Messaging     Messenger
+	CronTasks     []Cron
+	cronLocks     *cronLocker
+	jobOwner      string
+	Sequences     map[string]*Sequence
+	Logger        logging.Logger
 
 	AppVersion string
 	AppBuild   string
+
+	lifecycle    sync.Mutex
+	csrfExempt   map[string]bool
+	routes       []route
+	makingRoutes bool
+
+	servers        []*http.Server
+	serveErrors    chan error
+	stopBackground chan struct{}
+	stopped        chan struct{}
+	background     sync.WaitGroup
 }
 
 func New(conf Config, allCtrs []interface{}, allModels []interface{}, build ...string) (man *Manager, err error) {
+	return NewFS(conf, nil, allCtrs, allModels, build...)
+}
+
+// NewFS creates Manager with static files (templates and web) from fsys, eg. embed.FS with "static" directory,
+// disk StaticPath is used when fsys is nil (see Config StaticOverlay and ForceLiveStatic)
+func NewFS(conf Config, fsys fs.FS, allCtrs []interface{}, allModels []interface{}, build ...string) (man *Manager, err error) {
 	man = &Manager{
-		Config:     conf,
-		router:     httprouter.New(),
-		Dbc:        &Db{},
-		Views:      &ViewSet{},
-		Clients:    conf.Clients,
-		StaticFsys: os.DirFS("./static"),
+		Config:  conf,
+		router:  httprouter.New(),
+		Dbc:     &Db{},
+		Views:   &ViewSet{},
+		Clients: conf.Clients,
 	}
+	man.StaticFsys = man.staticFS(fsys)
+	man.Config.MapAuthGroups()
 
 	if len(build) > 0 {
 		if len(build[0]) == 0 {
@@ -86,11 +118,25 @@ func New(conf Config, allCtrs []interface{}, allModels []interface{}, build ...s
 		man.modelsReflected[name] = typ
 	}
 
+	for _, cronConf := range conf.Cron {
+		task, errCron := man.cronFromConfig(cronConf)
+		if errCron != nil {
+			err = fmt.Errorf("ERROR Manager New: %w", errCron)
+			return
+		}
+		man.CronTasks = append(man.CronTasks, task)
+	}
+
 	man.sessionManager = scs.New()
 	man.sessionManager.Lifetime = defaultSessionLifetime
 	if conf.SessionLifetimeHours > 0 {
 		man.sessionManager.Lifetime = time.Duration(conf.SessionLifetimeHours) * time.Hour
 	}
+	if conf.Auth.RememberMe {
+		man.sessionManager.Cookie.Persist = false
+	}
+
+	man.Authenticator = NewAuthenticator(conf.Auth)
 
 	man.Mid = NewMiddlewareManager()
 	man.MakeRoutes()
@@ -98,8 +144,12 @@ func New(conf Config, allCtrs []interface{}, allModels []interface{}, build ...s
 
 	err = man.Views.Load(&conf, man)
 	if err != nil {
-		err = fmt.Errorf("ERROR Manager New: views set failed: %w", err)
-		return
+		if !conf.ForceLiveStatic {
+			err = fmt.Errorf("ERROR Manager New: views set failed: %w", err)
+			return
+		}
+		// in live mode broken templates are shown on developer error page until fixed
+		log.Printf("Manager New: views set failed: %v", err)
 	}
 
 	err = man.Dbc.Check(man.Config.Db)
@@ -107,6 +157,18 @@ func New(conf Config, allCtrs []interface{}, allModels []interface{}, build ...s
 		err = fmt.Errorf("ERROR Manager New: Database check error:\n%v", err)
 	}
 
+	errSession := man.setupSessions(conf.Session)
+	if errSession != nil {
+		err = fmt.Errorf("ERROR Manager New: sessions setup failed: %w", errSession)
+		return
+	}
+
+	man.Locks = man.newLocker()
+	man.jobOwner = instanceName()
+	if strings.EqualFold(conf.CronLock, "db") {
+		man.cronLocks = newCronLocker(man.Dbc, time.Duration(conf.CronLeaseSeconds)*time.Second)
+	}
+
 	if conf.SlackHook != nil {
 		man.Messaging = &Slack{HookUrl: *conf.SlackHook}
 	}
@@ -130,12 +192,7 @@ func (man *Manager) ReloadStaticFS(fsys fs.FS) (err error) {
 		err = fmt.Errorf("manager reloading static FS: received nil")
 		return
 	}
-	subStatic, errSub := fs.Sub(fsys, "static")
-	if errSub == nil {
-		man.StaticFsys = subStatic
-	} else {
-		man.StaticFsys = fsys
-	}
+	man.StaticFsys = man.staticFS(fsys)
 
 	err = man.Views.Load(&man.Config, man)
 	if err != nil {
@@ -149,10 +206,38 @@ func (man *Manager) ReloadStaticFS(fsys fs.FS) (err error) {
 	return
 }
 
+// Migrate migrates app models and manago tables of enabled features (see internalModels),
+// sequences should be added with AddSequence before calling it
 func (man *Manager) Migrate() error {
+	err := man.Dbc.AutoMigrate(man.internalModels())
+	if err != nil {
+		return err
+	}
+
 	return man.Dbc.AutoMigrate(man.modelsReflected)
 }
 
+// internalModels are manago own tables needed by enabled features: sequences (added with AddSequence),
+// db cron locks (CronLock "db"), job queue (Jobs Workers set) and db session store (Session Store "db")
+func (man *Manager) internalModels() map[string]reflect.Type {
+	models := map[string]reflect.Type{}
+
+	if len(man.Sequences) > 0 {
+		models["manago_sequence"] = reflect.TypeOf(SequenceCounter{})
+	}
+	if strings.EqualFold(man.Config.CronLock, "db") {
+		models["manago_cron_lock"] = reflect.TypeOf(CronLock{})
+	}
+	if man.Config.Jobs.Workers > 0 {
+		models["manago_job"] = reflect.TypeOf(Job{})
+	}
+	if strings.EqualFold(man.Config.Session.Store, "db") {
+		models["manago_session"] = reflect.TypeOf(SessionData{})
+	}
+
+	return models
+}
+
 func (man *Manager) Start() (status string) {
 
 	status = fmt.Sprintf("Manager Start http server: %s:%d\n", man.Config.Server.Host, man.Config.Server.Port)
@@ -160,31 +245,14 @@ func (man *Manager) Start() (status string) {
 		status = fmt.Sprintf("%s + redirecting from ports: %v\n", status, man.Config.Server.RedirectFromPorts)
 	}
 
-	for _, redirPort := range man.Config.Server.RedirectFromPorts {
-		redirS := &http.Server{
-			Addr:         fmt.Sprintf("%s:%d", man.Config.Server.Host, redirPort),
-			Handler:      http.RedirectHandler(fmt.Sprintf("http://%s:%d", man.Config.Server.Host, man.Config.Server.Port), 301),
-			ReadTimeout:  httpServerTimeout,
-			WriteTimeout: 2 * httpServerTimeout,
-		}
-		go func() {
-			log.Print(redirS.ListenAndServe())
-		}()
+	err := man.listen("", "")
+	if err != nil {
+		status += fmt.Sprintf("Manager Start failed: %v\n", err)
+		return
 	}
-	go func() {
-		router := man.sessionManager.LoadAndSave(man.router)
-		srv := &http.Server{
-			Addr:         fmt.Sprintf("%s:%d", man.Config.Server.Host, man.Config.Server.Port),
-			Handler:      router,
-			ReadTimeout:  httpServerTimeout,
-			WriteTimeout: 2 * httpServerTimeout,
-		}
-		log.Print(srv.ListenAndServe())
-	}()
 
 	if len(man.CronTasks) > 0 {
 		status += "Starting Cron loop."
-		go man.CronLoop()
 	}
 
 	return
@@ -192,31 +260,224 @@ func (man *Manager) Start() (status string) {
 
 func (man *Manager) StartTls(certFile string, keyFile string) (status string) {
 
-	router := man.sessionManager.LoadAndSave(man.router)
-
 	status = fmt.Sprintf("Manager Start http server: %s:%d, with cert file: %s and key file: %s\n", man.Config.Server.Host, man.Config.Server.Port, certFile, keyFile)
-	go func() {
-		srv := &http.Server{
-			Addr:         fmt.Sprintf("%s:%d", man.Config.Server.Host, man.Config.Server.Port),
-			Handler:      router,
-			ReadTimeout:  httpServerTimeout,
-			WriteTimeout: 2 * httpServerTimeout,
+
+	err := man.listen(certFile, keyFile)
+	if err != nil {
+		status += fmt.Sprintf("Manager StartTls failed: %v\n", err)
+		return
+	}
+
+	if len(man.CronTasks) > 0 {
+		status += "Starting Cron loop."
+	}
+
+	return
+}
+
+// Run starts http server (with redirect servers and cron loop) and blocks until ctx is done,
+// SIGINT/SIGTERM is received, one of the servers fails or Shutdown is called. Everything is shut down before returning.
+func (man *Manager) Run(ctx context.Context) error {
+	return man.run(ctx, "", "")
+}
+
+// RunTls works like Run, serving https with provided cert and key files.
+func (man *Manager) RunTls(ctx context.Context, certFile string, keyFile string) error {
+	return man.run(ctx, certFile, keyFile)
+}
+
+func (man *Manager) run(ctx context.Context, certFile string, keyFile string) (err error) {
+	err = man.listen(certFile, keyFile)
+	if err != nil {
+		return
+	}
+	log.Printf("Manager Run: serving http on %s:%d", man.Config.Server.Host, man.Config.Server.Port)
+
+	man.lifecycle.Lock()
+	serveErrors := man.serveErrors
+	stopped := man.stopped
+	man.lifecycle.Unlock()
+	if stopped == nil {
+		// Shutdown was called right after listen
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case <-stopped:
+		log.Println("Manager Run: stopped by Shutdown")
+		return nil
+	case <-ctx.Done():
+		log.Println("Manager Run: received stop signal, shutting down")
+	case err = <-serveErrors:
+		log.Printf("Manager Run: server failed, shutting down: %v", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return errors.Join(err, man.Shutdown(shutdownCtx))
+}
+
+// Shutdown stops redirect servers and the main server (waiting for in-flight requests),
+// then background loops (cron) and running cron tasks, closes database pool and finally the Logger.
+// Run started before returns once Shutdown is done.
+func (man *Manager) Shutdown(ctx context.Context) (err error) {
+	man.lifecycle.Lock()
+	servers := man.servers
+	stopBackground := man.stopBackground
+	stopped := man.stopped
+	man.servers = nil
+	man.stopBackground = nil
+	man.stopped = nil
+	man.lifecycle.Unlock()
+
+	if servers == nil {
+		return fmt.Errorf("Manager Shutdown: not running")
+	}
+	defer close(stopped)
+
+	for _, srv := range servers {
+		errSrv := srv.Shutdown(ctx)
+		if errSrv != nil {
+			err = errors.Join(err, fmt.Errorf("Manager Shutdown: server %s: %w", srv.Addr, errSrv))
 		}
-		log.Print(srv.ListenAndServeTLS(certFile, keyFile))
+	}
+
+	close(stopBackground)
+	backgroundDone := make(chan struct{})
+	go func() {
+		man.background.Wait()
+		close(backgroundDone)
 	}()
+	select {
+	case <-backgroundDone:
+	case <-ctx.Done():
+		err = errors.Join(err, fmt.Errorf("Manager Shutdown: waiting for background loops: %w", ctx.Err()))
+	}
+
+	errDb := man.Dbc.Close()
+	if errDb != nil {
+		err = errors.Join(err, fmt.Errorf("Manager Shutdown: closing database: %w", errDb))
+	}
+
+	man.Logger.Close()
+
+	return
+}
+
+// listen binds main and redirect servers synchronously (so address errors are returned), starts serving them
+// and all background loops. Errors from running servers are passed to serveErrors.
+func (man *Manager) listen(certFile string, keyFile string) (err error) {
+	man.lifecycle.Lock()
+	defer man.lifecycle.Unlock()
+
+	if man.servers != nil {
+		return fmt.Errorf("Manager listen: already running")
+	}
+
+	useTls := len(certFile) > 0
+	scheme := "http"
+	if useTls {
+		scheme = "https"
+	}
+
+	mainSrv := man.newServer(man.Config.Server.Port, man.sessionManager.LoadAndSave(man.router))
+	servers := []*http.Server{}
+	for _, redirPort := range man.Config.Server.RedirectFromPorts {
+		redirHandler := http.RedirectHandler(fmt.Sprintf("%s://%s:%d", scheme, man.Config.Server.Host, man.Config.Server.Port), 301)
+		servers = append(servers, man.newServer(redirPort, redirHandler))
+	}
+	servers = append(servers, mainSrv)
+
+	listeners := []net.Listener{}
+	for _, srv := range servers {
+		ln, errLn := net.Listen("tcp", srv.Addr)
+		if errLn != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return fmt.Errorf("Manager listen on %s failed: %w", srv.Addr, errLn)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	man.servers = servers
+	man.serveErrors = make(chan error, len(servers))
+	man.stopBackground = make(chan struct{})
+	man.stopped = make(chan struct{})
+
+	for ix, srv := range servers {
+		go func(srv *http.Server, ln net.Listener) {
+			var errServe error
+			if useTls && srv == mainSrv {
+				errServe = srv.ServeTLS(ln, certFile, keyFile)
+			} else {
+				errServe = srv.Serve(ln)
+			}
+			if !errors.Is(errServe, http.ErrServerClosed) {
+				log.Printf("Manager server %s stopped: %v", srv.Addr, errServe)
+				man.serveErrors <- fmt.Errorf("Manager server %s: %w", srv.Addr, errServe)
+			}
+		}(srv, listeners[ix])
+	}
 
 	if len(man.CronTasks) > 0 {
-		status += "Starting Cron loop."
-		go man.CronLoop()
+		man.goBackground(man.cronLoop)
+	}
+	for ix := 0; ix < man.Config.Jobs.Workers; ix++ {
+		man.goBackground(man.jobWorker)
+	}
+	man.goBackground(man.dbStatsLoop)
+	man.goBackground(man.sessionCleanupLoop)
+	if man.Config.ForceLiveStatic {
+		conf := man.Config
+		man.goBackground(func(stop <-chan struct{}) {
+			man.Views.watchLoop(stop, conf)
+		})
 	}
 
 	return
 }
 
+func (man *Manager) newServer(port uint, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         fmt.Sprintf("%s:%d", man.Config.Server.Host, port),
+		Handler:      handler,
+		ReadTimeout:  httpServerTimeout,
+		WriteTimeout: 2 * httpServerTimeout,
+	}
+}
+
+// goBackground runs loop in a goroutine tracked by Shutdown, loop should return when stop is closed.
+// Must be called with lifecycle lock held.
+func (man *Manager) goBackground(loop func(stop <-chan struct{})) {
+	stop := man.stopBackground
+	man.background.Add(1)
+	go func() {
+		defer man.background.Done()
+		loop(stop)
+	}()
+}
+
+// goTracked runs fn in a goroutine Shutdown waits for, used for work started by background loops (cron runs)
+func (man *Manager) goTracked(fn func()) {
+	man.background.Add(1)
+	go func() {
+		defer man.background.Done()
+		fn()
+	}()
+}
+
 func (man *Manager) MakeRoutes() {
 
 	man.makeStaticRoutes()
 
+	man.makingRoutes = true
+	defer func() { man.makingRoutes = false }()
+
 	for _, typ := range man.controllersReflected {
 		log.Printf("Manager MakeRoutes: preparing routes for %s", typ.Name())
 		ctr := reflect.New(typ).Interface().(Controlled)
@@ -225,6 +486,9 @@ func (man *Manager) MakeRoutes() {
 		ctr.SetRoutes()
 	}
 
+	for _, rt := range man.routes {
+		man.router.Handle(rt.method, rt.path, rt.handle)
+	}
 }
 
 func (man *Manager) makeStaticRoutes() error {
@@ -275,18 +539,7 @@ func (man *Manager) HandleJson(ctrName, mtdName string) httprouter.Handle {
 
 		log.Printf("%T HandleJson: method[%v]", ctr, mtdName)
 
-		ctr.SetReqData(r, ps)
-		ctr.SetManager(man)
-
-		db, err := ctr.SetupDB(man.Dbc)
-		if err != nil {
-			log.Print(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		err = ctr.StartSession(man.sessionManager, w, r)
+		err := man.prepareController(ctr, w, r, ps, &requestStarted)
 		defer ctr.SessionRelease(w)
 		if err != nil {
 			log.Print(err.Error())
@@ -294,34 +547,13 @@ func (man *Manager) HandleJson(ctrName, mtdName string) httprouter.Handle {
 			return
 		}
 
-		var middlewarePermission bool
-
-		if man.Config.DevSkipMiddleware && man.AppVersion == "v_dev" {
-			middlewarePermission = true
-		} else {
-			middlewarePermission = man.Mid.ctrRunBefore(ctrName, mtdName, ctr)
-		}
-
-		if middlewarePermission {
-			method.Call([]reflect.Value{})
-		}
-
-		json, errJson := ctr.JsonCtnt()
-		if errJson != nil {
-			ctr.SetError(500, fmt.Errorf("HandleJson parsing to json failed:\n%v", errJson))
+		if man.verifyCsrf(ctrName, mtdName, ctr, r) {
+			man.runWithMiddleware(ctrName, mtdName, ctr, func() {
+				method.Call([]reflect.Value{})
+			})
 		}
 
-		if ctr.IsError() {
-			log.Print("Error from controller detected, serving:")
-			log.Print(ctr.GetError().Err)
-
-			man.Logger.LogError(r.URL.Path, "json", ctr.GetError().Err, ctr.GetError().Code)
-
-			http.Error(w, ctr.GetError().Msg, ctr.GetError().Code)
-		} else {
-			w.Header().Set("Content-Type", "application/json")
-			w.Write(json)
-		}
+		man.respondJson(w, r, ctr, "json")
 
 		man.Logger.LogExecutionTime(r.URL.Path, "json", time.Since(requestStarted))
 	}
@@ -379,22 +611,7 @@ func (man *Manager) Handle(params ...string) httprouter.Handle {
 
 		log.Printf("%T Handle: method[%v], template[%v]", ctr, mtdName, tmplName)
 
-		ctr.SetReqData(r, ps)
-
-		ctr.SetManager(man)
-
-		ctr.SetRequestStartTime(&requestStarted)
-
-		db, err := ctr.SetupDB(man.Dbc)
-
-		if err != nil {
-			log.Print(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		err = ctr.StartSession(man.sessionManager, w, r)
+		err := man.prepareController(ctr, w, r, ps, &requestStarted)
 		defer ctr.SessionRelease(w)
 		if err != nil {
 			log.Print(err.Error())
@@ -406,37 +623,13 @@ func (man *Manager) Handle(params ...string) httprouter.Handle {
 			ctr.SetRedir(redirAddr)
 		}
 
-		var middlewarePermission bool
-
-		if man.Config.DevSkipMiddleware && man.AppVersion == "v_dev" {
-			middlewarePermission = true
-		} else {
-			middlewarePermission = man.Mid.ctrRunBefore(ctrName, mtdName, ctr)
+		if man.verifyCsrf(ctrName, mtdName, ctr, r) {
+			man.runWithMiddleware(ctrName, mtdName, ctr, func() {
+				method.Call([]reflect.Value{})
+			})
 		}
 
-		if middlewarePermission {
-			method.Call([]reflect.Value{})
-		}
-
-		if ctr.IsError() {
-			log.Print("Error from controller detected, serving:")
-			log.Print(ctr.GetError().Err)
-
-			man.Logger.LogError(r.URL.Path, "handler", ctr.GetError().Err, ctr.GetError().Code)
-
-			http.Error(w, ctr.GetError().Msg, ctr.GetError().Code)
-		} else {
-			redirS, redirAddrS := ctr.GetRedir()
-			if redirS {
-				http.Redirect(w, r, redirAddrS, http.StatusSeeOther)
-			} else {
-				ctr.FillExecutionTime()
-				err := man.Views.FireTemplate(tmplName, w, ctr.Ctnt())
-				if err != nil {
-					log.Print(err.Error())
-				}
-			}
-		}
+		man.respondTemplate(w, r, ctr, tmplName, "handler")
 
 		man.Logger.LogExecutionTime(r.URL.Path, "handler", time.Since(requestStarted))
 
@@ -483,20 +676,7 @@ func (man *Manager) HandleDirect(params ...string) httprouter.Handle {
 
 		log.Printf("%T HandleDirect: method[%v]", ctr, mtdName)
 
-		ctr.SetReqData(r, ps)
-
-		ctr.SetManager(man)
-
-		db, err := ctr.SetupDB(man.Dbc)
-
-		if err != nil {
-			log.Print(err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		defer db.Close()
-
-		err = ctr.StartSession(man.sessionManager, w, r)
+		err := man.prepareController(ctr, w, r, ps, &requestStarted)
 		defer ctr.SessionRelease(w)
 		if err != nil {
 			log.Print(err.Error())
@@ -504,16 +684,10 @@ func (man *Manager) HandleDirect(params ...string) httprouter.Handle {
 			return
 		}
 
-		var middlewarePermission bool
-
-		if man.Config.DevSkipMiddleware && man.AppVersion == "v_dev" {
-			middlewarePermission = true
-		} else {
-			middlewarePermission = man.Mid.ctrRunBefore(ctrName, mtdName, ctr)
-		}
-
-		if middlewarePermission {
-			method.Call(input)
+		if man.verifyCsrf(ctrName, mtdName, ctr, r) {
+			man.runWithMiddleware(ctrName, mtdName, ctr, func() {
+				method.Call(input)
+			})
 		}
 
 		if ctr.IsError() {
@@ -529,6 +703,109 @@ func (man *Manager) HandleDirect(params ...string) httprouter.Handle {
 	}
 }
 
+// prepareController sets request data, database session and user session on controller
+func (man *Manager) prepareController(ctr Controlled, w http.ResponseWriter, r *http.Request, ps httprouter.Params, started *time.Time) error {
+	ctr.SetReqData(r, ps)
+	ctr.SetManager(man)
+	ctr.SetRequestStartTime(started)
+
+	_, err := ctr.SetupDB(man.Dbc)
+	if err != nil {
+		return err
+	}
+
+	return ctr.StartSession(man.sessionManager, w, r)
+}
+
+// respondJson writes view content as json or error (with validation errors as json, see writeJsonError)
+func (man *Manager) respondJson(w http.ResponseWriter, r *http.Request, ctr Controlled, handlerType string) {
+	json, errJson := ctr.JsonCtnt()
+	if errJson != nil {
+		ctr.SetError(500, fmt.Errorf("HandleJson parsing to json failed:\n%v", errJson))
+	}
+
+	if !ctr.IsError() && len(ctr.GetValidationErrors()) > 0 {
+		ctr.SetError(http.StatusUnprocessableEntity, ctr.GetValidationErrors(), "Validation failed")
+	}
+
+	if ctr.IsError() {
+		log.Print("Error from controller detected, serving:")
+		log.Print(ctr.GetError().Err)
+
+		man.Logger.LogError(r.URL.Path, handlerType, ctr.GetError().Err, ctr.GetError().Code)
+
+		man.writeJsonError(w, ctr.GetError())
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(json)
+	}
+}
+
+// respondTemplate writes error, redirects or renders template with view content
+func (man *Manager) respondTemplate(w http.ResponseWriter, r *http.Request, ctr Controlled, tmplName string, handlerType string) {
+	if ctr.IsError() {
+		log.Print("Error from controller detected, serving:")
+		log.Print(ctr.GetError().Err)
+
+		man.Logger.LogError(r.URL.Path, handlerType, ctr.GetError().Err, ctr.GetError().Code)
+
+		http.Error(w, ctr.GetError().Msg, ctr.GetError().Code)
+		return
+	}
+
+	redirS, redirAddrS := ctr.GetRedir()
+	if redirS {
+		http.Redirect(w, r, redirAddrS, http.StatusSeeOther)
+		return
+	}
+
+	ctr.FillExecutionTime()
+	(*ctr.Ctnt())["Flashes"] = man.popFlashes(r)
+	if len(ctr.GetValidationErrors()) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	err := man.Views.FireTemplateLayout(tmplName, ctr.GetLayout(), w, ctr.Ctnt())
+	if err != nil {
+		log.Print(err.Error())
+	}
+}
+
+// writeJsonError responds with plain error message, unless error wraps ValidationErrors,
+// then json with message and field errors is sent
+func (man *Manager) writeJsonError(w http.ResponseWriter, se StatusError) {
+	var fields ValidationErrors
+	if !errors.As(se.Err, &fields) {
+		http.Error(w, se.Msg, se.Code)
+		return
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"error": se.Msg, "errors": fields})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(se.Code)
+	w.Write(body)
+}
+
+// runWithMiddleware runs before middlewares, calls controller method if allowed and then after middlewares,
+// which are run also when method was not permitted or set an error.
+func (man *Manager) runWithMiddleware(ctrName, mtdName string, ctr Controlled, call func()) {
+	if man.Config.DevSkipMiddleware && man.AppVersion == "v_dev" {
+		call()
+		return
+	}
+
+	proceed, ran := man.Mid.ctrRunBefore(ctrName, mtdName, ctr)
+	if proceed {
+		call()
+	}
+
+	man.Mid.ctrRunAfter(ran, ctr)
+}
+
 func FileDirExists(path string) (bool, error) {
 	_, err := os.Stat(path)
 	if err == nil {
@@ -542,19 +819,60 @@ func FileDirExists(path string) (bool, error) {
 	return true, err
 }
 
+// CronLoop runs cron tasks forever, use Run (or Start) to get the loop stopped on Shutdown.
 func (man *Manager) CronLoop() {
+	man.cronLoop(nil)
+}
+
+// cronLoop sleeps until the nearest task run time, runs due tasks and schedules their next runs
+func (man *Manager) cronLoop(stop <-chan struct{}) {
 	for {
-		for taskIndex, _ := range man.CronTasks {
-			if man.CronTasks[taskIndex].CheckTime() {
-				err := man.CronTasks[taskIndex].RunMethod(man)
+		now := time.Now()
+		var wake time.Time
+
+		for taskIndex := range man.CronTasks {
+			task := &man.CronTasks[taskIndex]
+			if task.CheckTime() {
+				err := task.RunMethod(man)
 				if err != nil {
 					log.Printf("Error from Cron Task: %v\n", err)
 				}
 			}
+
+			next := task.NextRun()
+			if !next.IsZero() && (wake.IsZero() || next.Before(wake)) {
+				wake = next
+			}
+		}
+
+		if wake.IsZero() {
+			log.Println("Manager cronLoop: no more scheduled tasks")
+			<-stop
+			return
+		}
+
+		timer := time.NewTimer(wake.Sub(now))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
 		}
-		time.Sleep(5 * time.Second)
 	}
+}
 
+func (man *Manager) dbStatsLoop(stop <-chan struct{}) {
+	ticker := time.NewTicker(dbStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			man.Dbc.LogStats(man.Logger)
+		}
+	}
 }
 
 func (man *Manager) CopyDatabase() {
@@ -573,6 +891,7 @@ func (man *Manager) CopyDatabase() {
 		log.Println(err)
 		return
 	}
+	defer targetDb.Close()
 
 	log.Println("migrating target db...")
 	start := time.Now()
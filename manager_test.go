@@ -0,0 +1,275 @@
+package manago
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/hubertat/manago/logging"
+	"github.com/julienschmidt/httprouter"
+)
+
+// orderLog records lifecycle events in order they happened
+type orderLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (ol *orderLog) add(event string) {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+	ol.events = append(ol.events, event)
+}
+
+func (ol *orderLog) get() []string {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+	return append([]string{}, ol.events...)
+}
+
+type orderLogger struct {
+	logging.NilLogger
+	log *orderLog
+}
+
+func (ol *orderLogger) Close() {
+	ol.log.add("logger closed")
+}
+
+func freePort(t *testing.T) uint {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return uint(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func newLifecycleTestManager(port uint) *Manager {
+	return &Manager{
+		Config:         Config{Server: ServerConfig{Host: "127.0.0.1", Port: port}},
+		router:         httprouter.New(),
+		sessionManager: scs.New(),
+		Dbc:            &Db{},
+		Logger:         &logging.NilLogger{},
+	}
+}
+
+func runInBackground(man *Manager) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- man.Run(context.Background())
+	}()
+	return done
+}
+
+func waitServing(t *testing.T, man *Manager) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		man.lifecycle.Lock()
+		running := man.servers != nil
+		man.lifecycle.Unlock()
+		if running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("manager not serving")
+}
+
+func TestRunPortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	used := uint(ln.Addr().(*net.TCPAddr).Port)
+
+	tests := []struct {
+		name     string
+		port     uint
+		redirect []uint
+	}{
+		{"main port", used, nil},
+		{"redirect port", freePort(t), []uint{used}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			man := newLifecycleTestManager(tt.port)
+			man.Config.Server.RedirectFromPorts = tt.redirect
+
+			select {
+			case err := <-runInBackground(man):
+				if err == nil {
+					t.Error("Run on used port returned nil error")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run on used port did not return")
+			}
+
+			if man.servers != nil {
+				t.Error("servers left running after failed listen")
+			}
+		})
+	}
+}
+
+func TestRunReturnsAfterShutdown(t *testing.T) {
+	man := newLifecycleTestManager(freePort(t))
+	done := runInBackground(man)
+	waitServing(t, man)
+
+	err := man.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run returned %v after Shutdown", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Shutdown")
+	}
+
+	err = man.Shutdown(context.Background())
+	if err == nil {
+		t.Error("second Shutdown should report not running")
+	}
+}
+
+func TestShutdownOrder(t *testing.T) {
+	events := &orderLog{}
+	man := newLifecycleTestManager(freePort(t))
+	man.Logger = &orderLogger{log: events}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	man.router.HandlerFunc(http.MethodGet, "/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		events.add("request done")
+	})
+
+	done := runInBackground(man)
+	waitServing(t, man)
+
+	man.lifecycle.Lock()
+	man.goBackground(func(stop <-chan struct{}) {
+		<-stop
+		events.add("background stopped")
+	})
+	man.lifecycle.Unlock()
+
+	go http.Get(fmt.Sprintf("http://127.0.0.1:%d/slow", man.Config.Server.Port))
+	<-started
+
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- man.Shutdown(context.Background())
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	events.add("request released")
+	close(release)
+
+	select {
+	case err := <-shutdownDone:
+		if err != nil {
+			t.Fatalf("Shutdown failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return")
+	}
+	<-done
+
+	want := []string{"request released", "request done", "background stopped", "logger closed"}
+	if got := events.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("shutdown order = %v, want %v", got, want)
+	}
+}
+
+func TestMigrateInternalTables(t *testing.T) {
+	internal := []string{"manago_sequences", "manago_cron_locks", "manago_jobs", "manago_sessions"}
+
+	tests := []struct {
+		name      string
+		conf      Config
+		sequences bool
+		want      []string
+	}{
+		{"no features", Config{}, false, []string{}},
+		{"sequences", Config{}, true, []string{"manago_sequences"}},
+		{"db cron lock", Config{CronLock: "db"}, false, []string{"manago_cron_locks"}},
+		{"job workers", Config{Jobs: JobsConfig{Workers: 1}}, false, []string{"manago_jobs"}},
+		{"db sessions", Config{Session: SessionConfig{Store: "db"}}, false, []string{"manago_sessions"}},
+		{"memory locks and sessions", Config{CronLock: "memory", Session: SessionConfig{Store: "file"}}, false, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbc := &Db{}
+			err := dbc.Check(DatabaseConfig{Server: "sqlite", SqlitePath: filepath.Join(t.TempDir(), "test.db")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer dbc.Close()
+
+			man := &Manager{Config: tt.conf, Dbc: dbc, modelsReflected: map[string]reflect.Type{"db_test_item": reflect.TypeOf(dbTestItem{})}}
+			if tt.sequences {
+				man.AddSequence(NewSequence("invoice", "FV/{yyyy}/{nnnn}"))
+			}
+
+			err = man.Migrate()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, table := range internal {
+				if dbc.DB.HasTable(table) {
+					got = append(got, table)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("internal tables = %v, want %v", got, tt.want)
+			}
+			if !dbc.DB.HasTable(&dbTestItem{}) {
+				t.Error("app model not migrated")
+			}
+		})
+	}
+}
+
+func TestMigrateReportsErrors(t *testing.T) {
+	dbc := &Db{}
+	err := dbc.Check(DatabaseConfig{Server: "sqlite", SqlitePath: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbc.Close()
+
+	// view with table name makes creating table fail
+	err = dbc.DB.Exec("CREATE VIEW manago_jobs AS SELECT 1 AS id").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	man := &Manager{Config: Config{Jobs: JobsConfig{Workers: 1}, CronLock: "db"}, Dbc: dbc}
+	err = man.Migrate()
+	if err == nil {
+		t.Fatal("Migrate returned nil error for failed table")
+	}
+	if !dbc.DB.HasTable("manago_cron_locks") {
+		t.Error("other tables not migrated after failure")
+	}
+}
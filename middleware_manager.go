@@ -3,9 +3,13 @@ package manago
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 )
 
+// Middleware RunBefore is called before controller method, returning false stops method from running.
+// RunAfter is called after method (also when method was stopped or set an error), in reverse order,
+// only for middlewares which RunBefore was called (middlewares after the one returning false are skipped).
 type Middleware interface {
 	RunBefore(Controlled, map[string]string) bool
 	RunAfter(Controlled, map[string]string)
@@ -15,6 +19,7 @@ type MidMethodSet struct {
 	metName		string
 	middleware	Middleware
 	params		map[string]string
+	priority	int
 }
 
 type MidCtrSet struct {
@@ -22,8 +27,17 @@ type MidCtrSet struct {
 	methods		[]MidMethodSet
 }
 
+type MidGroupSet struct {
+	prefix		string
+	sets		[]MidMethodSet
+}
+
+// MiddlewareManager keeps middlewares attached globally (every controller), to path prefix groups
+// and to controller methods. For each request they are chained in that order and sorted by priority.
 type MiddlewareManager struct {
 	ctrMap					map[string]*MidCtrSet
+	global					[]MidMethodSet
+	groups					[]*MidGroupSet
 }
 
 func NewMiddlewareManager() *MiddlewareManager {
@@ -33,6 +47,13 @@ func NewMiddlewareManager() *MiddlewareManager {
 	return &mm
 }
 
+// Priority sets running order of middleware, lower runs first (default is 0),
+// middlewares with equal priority run in order of registration.
+func (ms *MidMethodSet) Priority(priority int) *MidMethodSet {
+	ms.priority = priority
+	return ms
+}
+
 func (mm *MiddlewareManager) ControllerSetRaw(ctrName string, middleware Middleware, params map[string]string, methods ...string) error {
 	ms := MidMethodSet{
 		middleware: middleware,
@@ -67,6 +88,33 @@ func (mm *MiddlewareManager) ControllerSet(ctrName string, set *MidMethodSet, me
 	return nil
 }
 
+// GlobalSet will attach middleware with params to every method of every controller
+func (mm *MiddlewareManager) GlobalSet(set *MidMethodSet) {
+	ms := *set
+	ms.metName = "_all"
+	mm.global = append(mm.global, ms)
+}
+
+// GroupSet will attach middleware with params to every request with url path starting with prefix (eg. "/admin/")
+func (mm *MiddlewareManager) GroupSet(prefix string, set *MidMethodSet) error {
+	if !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("MiddlewareManager GroupSet: prefix must start with /, received: %s", prefix)
+	}
+
+	ms := *set
+	ms.metName = "_all"
+
+	for _, group := range mm.groups {
+		if group.prefix == prefix {
+			group.sets = append(group.sets, ms)
+			return nil
+		}
+	}
+
+	mm.groups = append(mm.groups, &MidGroupSet{prefix: prefix, sets: []MidMethodSet{ms}})
+	return nil
+}
+
 func (mm *MiddlewareManager) GetSet(middleware Middleware, params ...string) *MidMethodSet {
 	ms := &MidMethodSet{
 		middleware: middleware,
@@ -86,38 +134,59 @@ func (mm *MiddlewareManager) GetSet(middleware Middleware, params ...string) *Mi
 	return ms 
 }
 
-func (mm *MiddlewareManager) ctrRunBefore(ctrName, mtdName string, ctr Controlled) (proceed bool) {
-	midCtr, ok := mm.ctrMap[ctrName]
+// chain returns middlewares matching controller method and request path, sorted by priority
+func (mm *MiddlewareManager) chain(ctrName, mtdName string, ctr Controlled) (chain []MidMethodSet) {
+	chain = append(chain, mm.global...)
 
-	proceed = true
+	if r := ctr.HttpRequest(); r != nil && r.URL != nil {
+		for _, group := range mm.groups {
+			if strings.HasPrefix(r.URL.Path, group.prefix) {
+				chain = append(chain, group.sets...)
+			}
+		}
+	}
 
+	midCtr, ok := mm.ctrMap[ctrName]
 	if ok {
 		for _, ms := range midCtr.methods {
 			if ms.metName == mtdName || ms.metName == "_all" {
-				log.Printf("Manago MiddlewareManager: running %T with %v\n", ms.middleware, ms.params)
-				proceed = proceed && ms.middleware.RunBefore(ctr, ms.params)
+				chain = append(chain, ms)
 			}
 		}
 	}
 
-	if !proceed {
-		log.Println("Manago MiddlewareManager: middleware finished, requested method will not proceed")
-	}
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].priority < chain[j].priority
+	})
 
 	return
 }
 
-func (mm *MiddlewareManager) ctrRunAfter(ctrName, mtdName string, ctr Controlled) {
-	midCtr, ok := mm.ctrMap[ctrName]
+// ctrRunBefore runs chain until middleware returns false, ran holds middlewares which RunBefore was called
+func (mm *MiddlewareManager) ctrRunBefore(ctrName, mtdName string, ctr Controlled) (proceed bool, ran []MidMethodSet) {
+	proceed = true
 
-	if ok {
-		for _, ms := range midCtr.methods {
-			if ms.metName == mtdName || ms.metName == "_all" {
-				log.Printf("Manago MiddlewareManager: running %T with %v\n", ms.middleware, ms.params)
-				ms.middleware.RunAfter(ctr, ms.params)
-			}
+	for _, ms := range mm.chain(ctrName, mtdName, ctr) {
+		log.Printf("Manago MiddlewareManager: running %T with %v\n", ms.middleware, ms.params)
+		ran = append(ran, ms)
+		if !ms.middleware.RunBefore(ctr, ms.params) {
+			proceed = false
+			break
 		}
 	}
 
+	if !proceed {
+		log.Println("Manago MiddlewareManager: middleware finished, requested method will not proceed")
+	}
+
 	return
-}
\ No newline at end of file
+}
+
+// ctrRunAfter runs RunAfter of middlewares returned by ctrRunBefore in reverse order
+func (mm *MiddlewareManager) ctrRunAfter(ran []MidMethodSet, ctr Controlled) {
+	for ix := len(ran) - 1; ix >= 0; ix-- {
+		ms := ran[ix]
+		log.Printf("Manago MiddlewareManager: running after %T with %v\n", ms.middleware, ms.params)
+		ms.middleware.RunAfter(ctr, ms.params)
+	}
+}
@@ -0,0 +1,109 @@
+package manago
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type recordingMiddleware struct {
+	name string
+	log  *[]string
+	stop bool
+}
+
+func (rm *recordingMiddleware) RunBefore(ctr Controlled, params map[string]string) bool {
+	*rm.log = append(*rm.log, "before "+rm.name)
+	return !rm.stop
+}
+
+func (rm *recordingMiddleware) RunAfter(ctr Controlled, params map[string]string) {
+	*rm.log = append(*rm.log, "after "+rm.name)
+}
+
+func TestMiddlewareChainOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantBefore []string
+		proceed    bool
+	}{
+		{"outside group", "/users/1", []string{"before auth", "before global", "before ctr"}, true},
+		{"inside group", "/admin/users", []string{"before auth", "before global", "before admin", "before ctr"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			mm := NewMiddlewareManager()
+			mm.GlobalSet(mm.GetSet(&recordingMiddleware{name: "global", log: &log}))
+			mm.GlobalSet(mm.GetSet(&recordingMiddleware{name: "auth", log: &log}).Priority(-10))
+			if err := mm.GroupSet("/admin/", mm.GetSet(&recordingMiddleware{name: "admin", log: &log})); err != nil {
+				t.Fatal(err)
+			}
+			if err := mm.ControllerSet("users", mm.GetSet(&recordingMiddleware{name: "ctr", log: &log}).Priority(5), "Show"); err != nil {
+				t.Fatal(err)
+			}
+
+			ctr := &Controller{}
+			ctr.Req.R = httptest.NewRequest("GET", tt.path, nil)
+
+			proceed, ran := mm.ctrRunBefore("users", "Show", ctr)
+			if proceed != tt.proceed {
+				t.Errorf("proceed = %v, want %v", proceed, tt.proceed)
+			}
+			if !reflect.DeepEqual(log, tt.wantBefore) {
+				t.Errorf("before order = %v, want %v", log, tt.wantBefore)
+			}
+
+			log = nil
+			mm.ctrRunAfter(ran, ctr)
+			if len(log) != len(tt.wantBefore) || log[0] != "after ctr" {
+				t.Errorf("after order = %v, want reversed chain", log)
+			}
+		})
+	}
+}
+
+func TestMiddlewareGroupSetPrefix(t *testing.T) {
+	mm := NewMiddlewareManager()
+	if err := mm.GroupSet("admin", mm.GetSet(&recordingMiddleware{log: &[]string{}})); err == nil {
+		t.Error("GroupSet accepted prefix without leading slash")
+	}
+}
+
+func TestMiddlewareStopsChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		stop    string
+		proceed bool
+		want    []string
+	}{
+		{"no stop", "", true, []string{"before first", "before auth", "before tx", "after tx", "after auth", "after first"}},
+		{"auth stops", "auth", false, []string{"before first", "before auth", "after auth", "after first"}},
+		{"first stops", "first", false, []string{"before first", "after first"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log []string
+			mm := NewMiddlewareManager()
+			for _, name := range []string{"first", "auth", "tx"} {
+				mm.GlobalSet(mm.GetSet(&recordingMiddleware{name: name, log: &log, stop: name == tt.stop}))
+			}
+
+			ctr := &Controller{}
+			ctr.Req.R = httptest.NewRequest("GET", "/", nil)
+
+			proceed, ran := mm.ctrRunBefore("users", "Show", ctr)
+			if proceed != tt.proceed {
+				t.Errorf("proceed = %v, want %v", proceed, tt.proceed)
+			}
+			mm.ctrRunAfter(ran, ctr)
+
+			if !reflect.DeepEqual(log, tt.want) {
+				t.Errorf("ran = %v, want %v", log, tt.want)
+			}
+		})
+	}
+}
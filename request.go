@@ -21,6 +21,9 @@ type Request struct {
 	redirAddress string
 	params       httprouter.Params
 	startTime    *time.Time
+
+	validationErrors ValidationErrors
+	layout           string
 }
 
 func (req *Request) SetData(r *http.Request, ps httprouter.Params) {
@@ -45,6 +48,11 @@ func (req *Request) SetRedir(addr string) {
 	req.redirAddress = addr
 }
 
+func (req *Request) ClearRedir() {
+	req.redir = false
+	req.redirAddress = ""
+}
+
 func (req *Request) AppendRedir(id uint) {
 	req.redirAddress = fmt.Sprintf("%s%d", req.redirAddress, id)
 }
@@ -0,0 +1,164 @@
+package manago
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	SequenceResetNever = "never"
+	SequenceResetYear  = "year"
+	SequenceResetMonth = "month"
+	SequenceResetDay   = "day"
+)
+
+var sequenceToken = regexp.MustCompile(`\{(yyyy|yy|mm|dd|n+)\}`)
+
+// SequenceCounter keeps last number allocated for sequence in period, table is created by Manager Migrate
+type SequenceCounter struct {
+	ID        uint
+	Name      string `gorm:"unique_index:idx_manago_sequence_period;not null"`
+	Period    string `gorm:"unique_index:idx_manago_sequence_period;not null"`
+	Value     uint
+	UpdatedAt time.Time
+}
+
+func (SequenceCounter) TableName() string {
+	return "manago_sequences"
+}
+
+// Sequence generates numbers formatted by Pattern, eg. "FV/{yyyy}/{nnnn}" gives "FV/2024/0007".
+// Tokens: {yyyy}, {yy}, {mm}, {dd} for date and {n...} for number (zero padded to count of n).
+// Counter is reset every Reset period (year, month, day or never), detected from pattern when empty.
+type Sequence struct {
+	Name    string
+	Pattern string
+	Reset   string
+}
+
+func NewSequence(name string, pattern string) *Sequence {
+	seq := &Sequence{Name: name, Pattern: pattern}
+
+	switch {
+	case strings.Contains(pattern, "{dd}"):
+		seq.Reset = SequenceResetDay
+	case strings.Contains(pattern, "{mm}"):
+		seq.Reset = SequenceResetMonth
+	case strings.Contains(pattern, "{yyyy}") || strings.Contains(pattern, "{yy}"):
+		seq.Reset = SequenceResetYear
+	default:
+		seq.Reset = SequenceResetNever
+	}
+
+	return seq
+}
+
+func (seq *Sequence) period(at time.Time) string {
+	switch seq.Reset {
+	case SequenceResetYear:
+		return at.Format("2006")
+	case SequenceResetMonth:
+		return at.Format("2006-01")
+	case SequenceResetDay:
+		return at.Format("2006-01-02")
+	}
+
+	return ""
+}
+
+// Format returns number formatted with pattern for date at
+func (seq *Sequence) Format(at time.Time, value uint) string {
+	return sequenceToken.ReplaceAllStringFunc(seq.Pattern, func(token string) string {
+		switch token {
+		case "{yyyy}":
+			return at.Format("2006")
+		case "{yy}":
+			return at.Format("06")
+		case "{mm}":
+			return at.Format("01")
+		case "{dd}":
+			return at.Format("02")
+		}
+
+		return fmt.Sprintf("%0*d", len(token)-2, value)
+	})
+}
+
+// NextTx allocates next number inside provided transaction, counter row stays locked until transaction ends,
+// so number is not used when transaction is rolled back (numbers are gapless)
+func (seq *Sequence) NextTx(tx *gorm.DB, at time.Time) (number string, value uint, err error) {
+	period := seq.period(at)
+
+	res := tx.Model(&SequenceCounter{}).Where("name = ? AND period = ?", seq.Name, period).
+		UpdateColumns(map[string]interface{}{"value": gorm.Expr("value + 1"), "updated_at": time.Now()})
+	if res.Error != nil {
+		err = fmt.Errorf("Sequence %s NextTx: updating counter failed: %w", seq.Name, res.Error)
+		return
+	}
+
+	if res.RowsAffected == 0 {
+		counter := SequenceCounter{Name: seq.Name, Period: period, Value: 1}
+		err = tx.Create(&counter).Error
+		if err != nil {
+			err = fmt.Errorf("Sequence %s NextTx: creating counter failed: %w", seq.Name, err)
+			return
+		}
+		value = counter.Value
+	} else {
+		counter := SequenceCounter{}
+		err = tx.Where("name = ? AND period = ?", seq.Name, period).First(&counter).Error
+		if err != nil {
+			err = fmt.Errorf("Sequence %s NextTx: reading counter failed: %w", seq.Name, err)
+			return
+		}
+		value = counter.Value
+	}
+
+	number = seq.Format(at, value)
+	return
+}
+
+// Next allocates next number in its own transaction, retried once when counter for new period was created concurrently
+func (seq *Sequence) Next(db *gorm.DB, at time.Time) (number string, err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		tx := db.Begin()
+		if tx.Error != nil {
+			return "", fmt.Errorf("Sequence %s Next: starting transaction failed: %w", seq.Name, tx.Error)
+		}
+
+		number, _, err = seq.NextTx(tx, at)
+		if err != nil {
+			tx.Rollback()
+			continue
+		}
+
+		err = tx.Commit().Error
+		if err == nil {
+			return
+		}
+	}
+
+	return "", err
+}
+
+// AddSequence registers sequence to be used by name from controllers (Controller NextNumber)
+func (man *Manager) AddSequence(seq *Sequence) {
+	if man.Sequences == nil {
+		man.Sequences = make(map[string]*Sequence)
+	}
+
+	man.Sequences[seq.Name] = seq
+}
+
+func (man *Manager) sequence(name string) (*Sequence, error) {
+	seq, present := man.Sequences[name]
+	if !present {
+		return nil, fmt.Errorf("sequence %s not found, add it with Manager AddSequence", name)
+	}
+
+	return seq, nil
+}
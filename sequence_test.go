@@ -0,0 +1,68 @@
+package manago
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSequenceFormat(t *testing.T) {
+	at := time.Date(2024, 3, 7, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		pattern string
+		value   uint
+		reset   string
+		want    string
+	}{
+		{"FV/{yyyy}/{nnnn}", 7, SequenceResetYear, "FV/2024/0007"},
+		{"{yy}{mm}-{nnn}", 12, SequenceResetMonth, "2403-012"},
+		{"{yyyy}{mm}{dd}/{n}", 5, SequenceResetDay, "20240307/5"},
+		{"ORD-{nn}", 123, SequenceResetNever, "ORD-123"},
+		{"{x}/{nnnn}", 1, SequenceResetNever, "{x}/0001"},
+	}
+
+	for _, tt := range tests {
+		seq := NewSequence("test", tt.pattern)
+		if seq.Reset != tt.reset {
+			t.Errorf("NewSequence(%q) Reset = %s, want %s", tt.pattern, seq.Reset, tt.reset)
+		}
+		got := seq.Format(at, tt.value)
+		if got != tt.want {
+			t.Errorf("Format(%q, %d) = %s, want %s", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSequenceNext(t *testing.T) {
+	dbc := newTestDb(t)
+	seq := NewSequence("invoice", "FV/{yyyy}/{nnn}")
+	march := time.Date(2024, 3, 7, 0, 0, 0, 0, time.UTC)
+	nextYear := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	for _, want := range []string{"FV/2024/001", "FV/2024/002"} {
+		got, err := seq.Next(dbc.DB, march)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("Next = %s, want %s", got, want)
+		}
+	}
+
+	got, err := seq.Next(dbc.DB, nextYear)
+	if err != nil || got != "FV/2025/001" {
+		t.Errorf("Next in new period = %s (%v), want FV/2025/001", got, err)
+	}
+
+	tx := dbc.DB.Begin()
+	got, _, err = seq.NextTx(tx, march)
+	if err != nil || got != "FV/2024/003" {
+		t.Errorf("NextTx = %s (%v), want FV/2024/003", got, err)
+	}
+	tx.Rollback()
+
+	got, err = seq.Next(dbc.DB, march)
+	if err != nil || got != "FV/2024/003" {
+		t.Errorf("Next after rollback = %s (%v), want FV/2024/003", got, err)
+	}
+}
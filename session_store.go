@@ -0,0 +1,254 @@
+package manago
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const sessionCleanupInterval = 5 * time.Minute
+
+// SessionData is scs session kept by DbSessionStore, table is created by Manager Migrate
+type SessionData struct {
+	Token  string `gorm:"primary_key;size:64"`
+	Data   []byte
+	Expiry time.Time `gorm:"index:idx_manago_session_expiry"`
+}
+
+func (SessionData) TableName() string {
+	return "manago_sessions"
+}
+
+// sessionCleaner is implemented by stores removing expired sessions in Manager background loop
+type sessionCleaner interface {
+	deleteExpired() error
+}
+
+// DbSessionStore (scs Store) keeps sessions in manago_sessions table of Manager database
+type DbSessionStore struct {
+	dbc *Db
+}
+
+func NewDbSessionStore(dbc *Db) *DbSessionStore {
+	return &DbSessionStore{dbc: dbc}
+}
+
+func (ds *DbSessionStore) Find(token string) ([]byte, bool, error) {
+	db, err := ds.dbc.Open()
+	if err != nil {
+		return nil, false, err
+	}
+
+	session := SessionData{}
+	err = db.Where("token = ? AND expiry > ?", token, time.Now().UTC()).First(&session).Error
+	if err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("DbSessionStore Find: %w", err)
+	}
+
+	return session.Data, true, nil
+}
+
+func (ds *DbSessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	db, err := ds.dbc.Open()
+	if err != nil {
+		return err
+	}
+
+	err = db.Save(&SessionData{Token: token, Data: b, Expiry: expiry.UTC()}).Error
+	if err != nil {
+		return fmt.Errorf("DbSessionStore Commit: %w", err)
+	}
+
+	return nil
+}
+
+func (ds *DbSessionStore) Delete(token string) error {
+	db, err := ds.dbc.Open()
+	if err != nil {
+		return err
+	}
+
+	return db.Where("token = ?", token).Delete(&SessionData{}).Error
+}
+
+func (ds *DbSessionStore) deleteExpired() error {
+	db, err := ds.dbc.Open()
+	if err != nil {
+		return err
+	}
+
+	return db.Where("expiry < ?", time.Now().UTC()).Delete(&SessionData{}).Error
+}
+
+// FileSessionStore (scs Store) keeps every session in separate file in Dir (file name is hash of token),
+// file holds expiry (unix nanoseconds) followed by session data
+type FileSessionStore struct {
+	Dir string
+}
+
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("NewFileSessionStore: %w", err)
+	}
+
+	return &FileSessionStore{Dir: dir}, nil
+}
+
+func (fs *FileSessionStore) path(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return filepath.Join(fs.Dir, hex.EncodeToString(sum[:])+".session")
+}
+
+func (fs *FileSessionStore) Find(token string) ([]byte, bool, error) {
+	content, err := ioutil.ReadFile(fs.path(token))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("FileSessionStore Find: %w", err)
+	}
+
+	if len(content) < 8 || time.Now().UnixNano() > int64(binary.BigEndian.Uint64(content)) {
+		return nil, false, fs.Delete(token)
+	}
+
+	return content[8:], true, nil
+}
+
+func (fs *FileSessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	content := make([]byte, 8+len(b))
+	binary.BigEndian.PutUint64(content, uint64(expiry.UnixNano()))
+	copy(content[8:], b)
+
+	tmp, err := ioutil.TempFile(fs.Dir, "tmp-")
+	if err != nil {
+		return fmt.Errorf("FileSessionStore Commit: %w", err)
+	}
+	_, err = tmp.Write(content)
+	errClose := tmp.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), fs.path(token))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("FileSessionStore Commit: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *FileSessionStore) Delete(token string) error {
+	err := os.Remove(fs.path(token))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("FileSessionStore Delete: %w", err)
+	}
+
+	return nil
+}
+
+func (fs *FileSessionStore) deleteExpired() error {
+	files, err := filepath.Glob(filepath.Join(fs.Dir, "*.session"))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+	for _, file := range files {
+		content, errRead := ioutil.ReadFile(file)
+		if errRead != nil {
+			continue
+		}
+		if len(content) < 8 || now > int64(binary.BigEndian.Uint64(content)) {
+			os.Remove(file)
+		}
+	}
+
+	return nil
+}
+
+// setupSessions applies Config Session to session manager, selecting store and cookie settings
+func (man *Manager) setupSessions(conf SessionConfig) error {
+	switch strings.ToLower(conf.Store) {
+	case "", "memory":
+	case "db":
+		if man.Dbc.DB == nil {
+			return fmt.Errorf("session store db: database not connected")
+		}
+		man.sessionManager.Store = NewDbSessionStore(man.Dbc)
+	case "file":
+		path := conf.FilePath
+		if len(path) == 0 {
+			path = filepath.Join(man.Config.TmpPath, "sessions")
+		}
+		store, err := NewFileSessionStore(path)
+		if err != nil {
+			return err
+		}
+		man.sessionManager.Store = store
+	default:
+		return fmt.Errorf("unknown session store [%s]", conf.Store)
+	}
+
+	if len(conf.CookieName) > 0 {
+		man.sessionManager.Cookie.Name = conf.CookieName
+	}
+	man.sessionManager.Cookie.Domain = conf.CookieDomain
+	man.sessionManager.Cookie.Secure = conf.CookieSecure
+
+	switch strings.ToLower(conf.CookieSameSite) {
+	case "", "lax":
+		man.sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+	case "strict":
+		man.sessionManager.Cookie.SameSite = http.SameSiteStrictMode
+	case "none":
+		man.sessionManager.Cookie.SameSite = http.SameSiteNoneMode
+	default:
+		return fmt.Errorf("unknown cookie SameSite mode [%s]", conf.CookieSameSite)
+	}
+
+	if conf.IdleTimeoutMinutes > 0 {
+		man.sessionManager.IdleTimeout = time.Duration(conf.IdleTimeoutMinutes) * time.Minute
+	}
+
+	return nil
+}
+
+// sessionCleanupLoop removes expired sessions from persistent store
+func (man *Manager) sessionCleanupLoop(stop <-chan struct{}) {
+	cleaner, isCleaner := man.sessionManager.Store.(sessionCleaner)
+	if !isCleaner {
+		return
+	}
+
+	ticker := time.NewTicker(sessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := cleaner.deleteExpired()
+			if err != nil {
+				log.Printf("Manager sessionCleanupLoop: %v", err)
+			}
+		}
+	}
+}
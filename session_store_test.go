@@ -0,0 +1,134 @@
+package manago
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+type testSessionStore interface {
+	scs.Store
+	sessionCleaner
+}
+
+func TestSessionStores(t *testing.T) {
+	fileStore, err := NewFileSessionStore(filepath.Join(t.TempDir(), "sessions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]testSessionStore{
+		"db":   NewDbSessionStore(newTestDb(t)),
+		"file": fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			valid := time.Now().Add(time.Hour)
+			expired := time.Now().Add(-time.Second)
+
+			for token, expiry := range map[string]time.Time{"valid": valid, "expired": expired, "removed": valid} {
+				err := store.Commit(token, []byte("data of "+token), expiry)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := store.Commit("valid", []byte("changed"), valid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = store.Delete("removed")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tests := []struct {
+				token string
+				found bool
+				data  string
+			}{
+				{"valid", true, "changed"},
+				{"expired", false, ""},
+				{"removed", false, ""},
+				{"missing", false, ""},
+			}
+			for _, tt := range tests {
+				data, found, err := store.Find(tt.token)
+				if err != nil || found != tt.found || string(data) != tt.data {
+					t.Errorf("Find(%s) = %q, %v, %v; want %q, %v", tt.token, data, found, err, tt.data, tt.found)
+				}
+			}
+
+			err = store.deleteExpired()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, found, _ := store.Find("valid")
+			if !found {
+				t.Error("deleteExpired removed valid session")
+			}
+		})
+	}
+}
+
+func TestDbSessionStoreDeleteExpired(t *testing.T) {
+	dbc := newTestDb(t)
+	store := NewDbSessionStore(dbc)
+	store.Commit("valid", []byte("a"), time.Now().Add(time.Hour))
+	store.Commit("expired", []byte("b"), time.Now().Add(-time.Second))
+
+	err := store.deleteExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	dbc.DB.Model(&SessionData{}).Count(&count)
+	if count != 1 {
+		t.Errorf("sessions after deleteExpired = %d, want 1", count)
+	}
+}
+
+func TestSetupSessions(t *testing.T) {
+	tests := []struct {
+		name string
+		conf SessionConfig
+		err  bool
+		want func(sm *scs.SessionManager) bool
+	}{
+		{"defaults", SessionConfig{}, false, func(sm *scs.SessionManager) bool {
+			return sm.Cookie.SameSite == http.SameSiteLaxMode && sm.Cookie.Name == "session"
+		}},
+		{"cookie", SessionConfig{CookieName: "app", CookieDomain: "example.com", CookieSecure: true, CookieSameSite: "Strict", IdleTimeoutMinutes: 30}, false, func(sm *scs.SessionManager) bool {
+			return sm.Cookie.Name == "app" && sm.Cookie.Domain == "example.com" && sm.Cookie.Secure &&
+				sm.Cookie.SameSite == http.SameSiteStrictMode && sm.IdleTimeout == 30*time.Minute
+		}},
+		{"db store", SessionConfig{Store: "db"}, false, func(sm *scs.SessionManager) bool {
+			_, isDb := sm.Store.(*DbSessionStore)
+			return isDb
+		}},
+		{"file store", SessionConfig{Store: "File", FilePath: filepath.Join(t.TempDir(), "s")}, false, func(sm *scs.SessionManager) bool {
+			_, isFile := sm.Store.(*FileSessionStore)
+			return isFile
+		}},
+		{"unknown store", SessionConfig{Store: "redis"}, true, nil},
+		{"unknown same site", SessionConfig{CookieSameSite: "loose"}, true, nil},
+	}
+
+	dbc := newTestDb(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			man := &Manager{Dbc: dbc, sessionManager: scs.New()}
+			err := man.setupSessions(tt.conf)
+			if (err != nil) != tt.err {
+				t.Fatalf("setupSessions error = %v, want error %v", err, tt.err)
+			}
+			if tt.want != nil && !tt.want(man.sessionManager) {
+				t.Errorf("session manager = %+v", man.sessionManager)
+			}
+		})
+	}
+}
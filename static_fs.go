@@ -0,0 +1,86 @@
+package manago
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+)
+
+// OverlayFS serves files from Upper when present there and from Lower otherwise,
+// directories list entries of both (Upper wins), eg. disk files patching embedded ones
+type OverlayFS struct {
+	Upper fs.FS
+	Lower fs.FS
+}
+
+func (ofs OverlayFS) Open(name string) (fs.File, error) {
+	file, err := ofs.Upper.Open(name)
+	if err == nil {
+		return file, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return ofs.Lower.Open(name)
+}
+
+func (ofs OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, errUpper := fs.ReadDir(ofs.Upper, name)
+	lower, errLower := fs.ReadDir(ofs.Lower, name)
+	if errUpper != nil && errLower != nil {
+		if !errors.Is(errUpper, fs.ErrNotExist) {
+			return nil, errUpper
+		}
+		return nil, errLower
+	}
+
+	merged := make(map[string]fs.DirEntry)
+	for _, entry := range lower {
+		merged[entry.Name()] = entry
+	}
+	for _, entry := range upper {
+		merged[entry.Name()] = entry
+	}
+
+	entries := make([]fs.DirEntry, 0, len(merged))
+	for _, entry := range merged {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	return entries, nil
+}
+
+// staticFS picks Manager static files: "static" directory of fsys (or fsys itself when it has no such directory),
+// disk StaticPath is laid over it with Config StaticOverlay or replaces it with ForceLiveStatic.
+// Without fsys disk StaticPath is used.
+func (man *Manager) staticFS(fsys fs.FS) fs.FS {
+	diskPath := man.Config.StaticPath
+	if len(diskPath) == 0 {
+		diskPath = "./static"
+	}
+	disk := os.DirFS(diskPath)
+
+	if fsys == nil {
+		return disk
+	}
+
+	info, err := fs.Stat(fsys, "static")
+	if err == nil && info.IsDir() {
+		fsys, _ = fs.Sub(fsys, "static")
+	}
+
+	switch {
+	case man.Config.StaticOverlay:
+		log.Printf("StaticOverlay set, files from %s override received FS.", diskPath)
+		return OverlayFS{Upper: disk, Lower: fsys}
+	case man.Config.ForceLiveStatic:
+		log.Printf("ForceLiveStatic set, using static files from %s instead of received FS.", diskPath)
+		return disk
+	}
+
+	return fsys
+}
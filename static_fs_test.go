@@ -0,0 +1,119 @@
+package manago
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestOverlayFS(t *testing.T) {
+	ofs := OverlayFS{
+		Upper: fstest.MapFS{
+			"web/app.css":     {Data: []byte("upper css")},
+			"web/extra.js":    {Data: []byte("upper js")},
+			"only/upper.html": {Data: []byte("upper only")},
+		},
+		Lower: fstest.MapFS{
+			"web/app.css":        {Data: []byte("lower css")},
+			"web/logo.png":       {Data: []byte("lower png")},
+			"templates/a.gohtml": {Data: []byte("lower template")},
+		},
+	}
+
+	files := []struct {
+		name string
+		want string
+	}{
+		{"web/app.css", "upper css"},
+		{"web/logo.png", "lower png"},
+		{"only/upper.html", "upper only"},
+		{"templates/a.gohtml", "lower template"},
+	}
+	for _, tt := range files {
+		got, err := fs.ReadFile(ofs, tt.name)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("ReadFile(%s) = %q (%v), want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	_, err := fs.ReadFile(ofs, "web/missing.css")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile of missing file error = %v, want fs.ErrNotExist", err)
+	}
+
+	dirs := []struct {
+		name string
+		want []string
+	}{
+		{"web", []string{"app.css", "extra.js", "logo.png"}},
+		{"templates", []string{"a.gohtml"}},
+		{"only", []string{"upper.html"}},
+	}
+	for _, tt := range dirs {
+		entries, err := fs.ReadDir(ofs, tt.name)
+		if err != nil {
+			t.Errorf("ReadDir(%s) failed: %v", tt.name, err)
+			continue
+		}
+		got := []string{}
+		for _, entry := range entries {
+			got = append(got, entry.Name())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReadDir(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	_, err = fs.ReadDir(ofs, "missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadDir of missing directory error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestStaticFS(t *testing.T) {
+	disk := t.TempDir()
+	err := os.WriteFile(filepath.Join(disk, "app.css"), []byte("disk"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedded := fstest.MapFS{
+		"static/app.css":  {Data: []byte("embedded")},
+		"static/logo.png": {Data: []byte("embedded png")},
+	}
+
+	tests := []struct {
+		name  string
+		conf  Config
+		fsys  fs.FS
+		file  string
+		want  string
+		exist bool
+	}{
+		{"disk without fsys", Config{StaticPath: disk}, nil, "app.css", "disk", true},
+		{"static directory of fsys", Config{StaticPath: disk}, embedded, "app.css", "embedded", true},
+		{"fsys without static directory", Config{StaticPath: disk}, fstest.MapFS{"app.css": {Data: []byte("root")}}, "app.css", "root", true},
+		{"overlay prefers disk", Config{StaticPath: disk, StaticOverlay: true}, embedded, "app.css", "disk", true},
+		{"overlay falls back to fsys", Config{StaticPath: disk, StaticOverlay: true}, embedded, "logo.png", "embedded png", true},
+		{"live static uses disk only", Config{StaticPath: disk, ForceLiveStatic: true}, embedded, "logo.png", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			man := &Manager{Config: tt.conf}
+			got, err := fs.ReadFile(man.staticFS(tt.fsys), tt.file)
+			if !tt.exist {
+				if !errors.Is(err, fs.ErrNotExist) {
+					t.Errorf("ReadFile(%s) error = %v, want fs.ErrNotExist", tt.file, err)
+				}
+				return
+			}
+			if err != nil || string(got) != tt.want {
+				t.Errorf("ReadFile(%s) = %q (%v), want %q", tt.file, got, err, tt.want)
+			}
+		})
+	}
+}
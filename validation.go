@@ -0,0 +1,236 @@
+package manago
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/iancoleman/strcase"
+)
+
+// ValidationErrors keeps error messages by form field name (snake case of struct field name)
+type ValidationErrors map[string]string
+
+func (ve ValidationErrors) Error() string {
+	parts := []string{}
+	for field, msg := range ve {
+		parts = append(parts, field+": "+msg)
+	}
+	return "validation failed: " + strings.Join(parts, ", ")
+}
+
+// Add keeps first error for field
+func (ve ValidationErrors) Add(field string, msg string) {
+	_, present := ve[field]
+	if !present {
+		ve[field] = msg
+	}
+}
+
+var emailRegex = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// parsedRules caches rules of validate tags, rules are checked when tag is parsed first time
+var parsedRules sync.Map
+
+type validationRule struct {
+	name  string
+	param string
+	limit float64
+	re    *regexp.Regexp
+}
+
+// Validate checks struct fields against rules from `validate` tag, eg. `validate:"required,min=3,max=50"`.
+// Supported rules: required, min, max (length for strings and slices, value for numbers), len, email,
+// oneof (space separated values) and regex; regex must be the last rule as it takes rest of the tag.
+// Empty strings and slices pass min, max, len, email, oneof and regex (use required to disallow).
+// Unknown rule, wrong rule parameter or regex is a programming error and panics.
+func Validate(model interface{}) ValidationErrors {
+	return validateNamed(model, formFieldName)
+}
+
+// formFieldName returns name of field in form (snake case of field name, see FillForm)
+func formFieldName(field reflect.StructField) string {
+	return strcase.ToSnake(field.Name)
+}
+
+// validateNamed works like Validate with errors keyed by fieldName
+func validateNamed(model interface{}, fieldName func(reflect.StructField) string) ValidationErrors {
+	errs := ValidationErrors{}
+
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if v.Kind() != reflect.Struct {
+		return errs
+	}
+
+	validateStruct(v, errs, fieldName)
+	return errs
+}
+
+func validateStruct(v reflect.Value, errs ValidationErrors, fieldName func(reflect.StructField) string) {
+	for ix := 0; ix < v.NumField(); ix++ {
+		field := v.Type().Field(ix)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			validateStruct(v.Field(ix), errs, fieldName)
+			continue
+		}
+
+		tag, present := field.Tag.Lookup("validate")
+		if !present || len(tag) == 0 {
+			continue
+		}
+
+		for _, rule := range rulesFor(v.Type().Name()+"."+field.Name, tag) {
+			msg := checkRule(v.Field(ix), rule)
+			if len(msg) > 0 {
+				errs.Add(fieldName(field), msg)
+				break
+			}
+		}
+	}
+}
+
+func rulesFor(fieldPath string, tag string) []validationRule {
+	cached, present := parsedRules.Load(tag)
+	if present {
+		return cached.([]validationRule)
+	}
+
+	rules := []validationRule{}
+	for _, ruleTag := range splitRules(tag) {
+		rule := validationRule{name: ruleTag}
+		pos := strings.Index(ruleTag, "=")
+		if pos > -1 {
+			rule.name, rule.param = ruleTag[:pos], ruleTag[pos+1:]
+		}
+
+		var err error
+		switch rule.name {
+		case "required", "email", "oneof":
+		case "min", "max", "len":
+			rule.limit, err = strconv.ParseFloat(rule.param, 64)
+		case "regex":
+			rule.re, err = regexp.Compile(rule.param)
+		default:
+			err = fmt.Errorf("unknown rule")
+		}
+		if err != nil {
+			panic(fmt.Sprintf("manago Validate: field %s has wrong validate rule %q: %v", fieldPath, ruleTag, err))
+		}
+
+		rules = append(rules, rule)
+	}
+
+	parsedRules.Store(tag, rules)
+	return rules
+}
+
+func splitRules(tag string) (rules []string) {
+	for len(tag) > 0 {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+
+		pos := strings.Index(tag, ",")
+		if pos < 0 {
+			return append(rules, tag)
+		}
+
+		rules = append(rules, tag[:pos])
+		tag = tag[pos+1:]
+	}
+
+	return
+}
+
+func checkRule(fv reflect.Value, rule validationRule) string {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			if rule.name == "required" {
+				return "is required"
+			}
+			return ""
+		}
+		fv = fv.Elem()
+	}
+
+	switch rule.name {
+	case "required":
+		if fv.IsZero() {
+			return "is required"
+		}
+
+	case "min", "max", "len":
+		size, isLength := measure(fv)
+		if isLength && size == 0 {
+			// empty optional value, use required to disallow
+			return ""
+		}
+		switch {
+		case rule.name == "min" && size < rule.limit:
+			if isLength {
+				return fmt.Sprintf("must be at least %s characters long", rule.param)
+			}
+			return fmt.Sprintf("must be at least %s", rule.param)
+		case rule.name == "max" && size > rule.limit:
+			if isLength {
+				return fmt.Sprintf("must be at most %s characters long", rule.param)
+			}
+			return fmt.Sprintf("must be at most %s", rule.param)
+		case rule.name == "len" && size != rule.limit:
+			if isLength {
+				return fmt.Sprintf("must be exactly %s characters long", rule.param)
+			}
+			return fmt.Sprintf("must be exactly %s", rule.param)
+		}
+
+	case "email":
+		if fv.Kind() == reflect.String && fv.Len() > 0 && !emailRegex.MatchString(fv.String()) {
+			return "must be a valid email address"
+		}
+
+	case "oneof":
+		if fv.IsZero() {
+			return ""
+		}
+		value := fmt.Sprint(fv.Interface())
+		for _, allowed := range strings.Fields(rule.param) {
+			if value == allowed {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of: %s", strings.Join(strings.Fields(rule.param), ", "))
+
+	case "regex":
+		if fv.Kind() != reflect.String || fv.Len() == 0 {
+			return ""
+		}
+		if !rule.re.MatchString(fv.String()) {
+			return "has wrong format"
+		}
+	}
+
+	return ""
+}
+
+// measure returns length for strings, slices and maps or value for numbers
+func measure(fv reflect.Value) (size float64, isLength bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fv.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), false
+	}
+
+	return 0, false
+}
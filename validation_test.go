@@ -0,0 +1,91 @@
+package manago
+
+import (
+	"reflect"
+	"testing"
+)
+
+type validationBase struct {
+	Code string `validate:"len=3"`
+}
+
+type validationModel struct {
+	validationBase
+	Name     string   `validate:"required,min=3,max=10"`
+	Email    string   `validate:"email"`
+	Age      int      `validate:"min=1,max=130"`
+	Score    *float64 `validate:"max=10"`
+	Role     string   `validate:"oneof=admin editor"`
+	Tags     []string `validate:"max=2"`
+	Zip      string   `validate:"regex=^[0-9]{2}-[0-9]{3}$"`
+	Nickname *string  `validate:"required"`
+	Pin      int      `validate:"len=4"`
+}
+
+func TestValidate(t *testing.T) {
+	nick := "nick"
+	high := 11.5
+	valid := validationModel{validationBase{"abc"}, "John", "john@example.com", 30, nil, "admin", []string{"a"}, "00-950", &nick, 4}
+
+	tests := []struct {
+		name   string
+		modify func(m *validationModel)
+		want   ValidationErrors
+	}{
+		{"valid", func(m *validationModel) {}, ValidationErrors{}},
+		{"empty optional values pass", func(m *validationModel) {
+			m.Code, m.Email, m.Role, m.Tags, m.Zip = "", "", "", nil, ""
+		}, ValidationErrors{}},
+		{"required", func(m *validationModel) { m.Name, m.Nickname = "", nil }, ValidationErrors{"name": "is required", "nickname": "is required"}},
+		{"string length", func(m *validationModel) { m.Name = "Jo" }, ValidationErrors{"name": "must be at least 3 characters long"}},
+		{"multibyte length", func(m *validationModel) { m.Name = "Łódź" }, ValidationErrors{}},
+		{"zero number checked by min", func(m *validationModel) { m.Age = 0 }, ValidationErrors{"age": "must be at least 1"}},
+		{"number max", func(m *validationModel) { m.Age = 131 }, ValidationErrors{"age": "must be at most 130"}},
+		{"zero number checked by len", func(m *validationModel) { m.Pin = 0 }, ValidationErrors{"pin": "must be exactly 4"}},
+		{"pointer value", func(m *validationModel) { m.Score = &high }, ValidationErrors{"score": "must be at most 10"}},
+		{"email", func(m *validationModel) { m.Email = "john@" }, ValidationErrors{"email": "must be a valid email address"}},
+		{"oneof", func(m *validationModel) { m.Role = "root" }, ValidationErrors{"role": "must be one of: admin, editor"}},
+		{"slice max", func(m *validationModel) { m.Tags = []string{"a", "b", "c"} }, ValidationErrors{"tags": "must be at most 2 characters long"}},
+		{"regex", func(m *validationModel) { m.Zip = "00950" }, ValidationErrors{"zip": "has wrong format"}},
+		{"embedded struct", func(m *validationModel) { m.Code = "abcd" }, ValidationErrors{"code": "must be exactly 3 characters long"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := valid
+			tt.modify(&model)
+			got := Validate(&model)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWrongRulePanics(t *testing.T) {
+	tests := []struct {
+		name  string
+		model interface{}
+	}{
+		{"unknown rule", &struct {
+			A string `validate:"requird"`
+		}{}},
+		{"wrong limit", &struct {
+			A string `validate:"min=x"`
+		}{}},
+		{"wrong regex", &struct {
+			A string `validate:"regex=[a-"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Validate did not panic")
+				}
+			}()
+			Validate(tt.model)
+		})
+	}
+}
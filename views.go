@@ -1,28 +1,72 @@
 package manago
 
 import (
+	"bytes"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
+const templatesWatchInterval = time.Second
+
 type ViewSet struct {
 	templatesLocation string
 	partialsLocation  string
 	ts                map[string]*template.Template
 	baseTemplate      *template.Template
 	man               *Manager
+
+	// live (Config ForceLiveStatic) renders load and execution errors as developer error page
+	live    bool
+	loadErr error
+	lock    sync.RWMutex
+	// fsys is Manager StaticFsys taken by Load, so reloading in watchLoop does not read Manager
+	fsys fs.FS
+
+	// layouts from front-matter of pages and layouts (see layout.go), variants are built on first render
+	pageLayouts   map[string]string
+	layoutParents map[string]string
+	variants      map[string]templateVariant
+	variantsLock  sync.Mutex
 }
 
 func (vs *ViewSet) Load(conf *Config, manager *Manager) (err error) {
+	vs.lock.Lock()
+	defer vs.lock.Unlock()
+
 	vs.man = manager
+	vs.fsys = manager.StaticFsys
+
+	return vs.load(conf)
+}
+
+// reload loads templates again from FS taken by last Load
+func (vs *ViewSet) reload(conf *Config) (err error) {
+	vs.lock.Lock()
+	defer vs.lock.Unlock()
+
+	return vs.load(conf)
+}
+
+// load parses templates from vs.fsys, must be called with lock held
+func (vs *ViewSet) load(conf *Config) (err error) {
+	vs.live = conf.ForceLiveStatic
 	vs.ts = make(map[string]*template.Template)
+	vs.baseTemplate = nil
+	vs.pageLayouts = make(map[string]string)
+	vs.layoutParents = make(map[string]string)
+	vs.variants = make(map[string]templateVariant)
+
+	defer func() {
+		vs.loadErr = err
+	}()
 
-	if manager.StaticFsys == nil {
+	if vs.fsys == nil {
 		err = fmt.Errorf("No static FS! Aborting")
 		return
 	}
@@ -30,12 +74,13 @@ func (vs *ViewSet) Load(conf *Config, manager *Manager) (err error) {
 	vs.templatesLocation = strings.Trim(conf.TemplatesPath, "\\/.")
 	vs.partialsLocation = "/partials"
 
-	err = fs.WalkDir(manager.StaticFsys, vs.templatesLocation, vs.walkForBase)
+	err = fs.WalkDir(vs.fsys, vs.templatesLocation, vs.walkForBase)
 	if err != nil {
 		err = fmt.Errorf("templates Load: walking for base failed: %w", err)
+		return
 	}
 
-	err = fs.WalkDir(manager.StaticFsys, vs.templatesLocation, vs.walkFolders)
+	err = fs.WalkDir(vs.fsys, vs.templatesLocation, vs.walkFolders)
 
 	if err != nil {
 		err = fmt.Errorf("templates Load: walking dir failed: %w", err)
@@ -44,6 +89,81 @@ func (vs *ViewSet) Load(conf *Config, manager *Manager) (err error) {
 	return
 }
 
+// templatesState returns modification times of files in templates location, used to detect changes
+func (vs *ViewSet) templatesState() (state map[string]time.Time, err error) {
+	state = make(map[string]time.Time)
+
+	vs.lock.RLock()
+	fsys, location := vs.fsys, vs.templatesLocation
+	vs.lock.RUnlock()
+
+	err = fs.WalkDir(fsys, location, func(path string, d fs.DirEntry, errWalk error) error {
+		if errWalk != nil || d.IsDir() {
+			return errWalk
+		}
+
+		info, errInfo := d.Info()
+		if errInfo != nil {
+			return errInfo
+		}
+		state[path] = info.ModTime()
+
+		return nil
+	})
+
+	return
+}
+
+// watchLoop polls templates (with partials) for changes and reloads them with conf (snapshot taken when loop
+// was started), load errors are shown on developer error page
+func (vs *ViewSet) watchLoop(stop <-chan struct{}, conf Config) {
+	known, err := vs.templatesState()
+	if err != nil {
+		log.Printf("ViewSet watchLoop: reading templates state failed: %v", err)
+	}
+
+	ticker := time.NewTicker(templatesWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current, err := vs.templatesState()
+		if err != nil {
+			log.Printf("ViewSet watchLoop: reading templates state failed: %v", err)
+			continue
+		}
+		if templatesStateEqual(known, current) {
+			continue
+		}
+		known = current
+
+		log.Println("ViewSet watchLoop: templates changed, reloading")
+		err = vs.reload(&conf)
+		if err != nil {
+			log.Printf("ViewSet watchLoop: reloading templates failed: %v", err)
+		}
+	}
+}
+
+func templatesStateEqual(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for path, modTime := range a {
+		if !b[path].Equal(modTime) {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (vs *ViewSet) walkFolders(path string, d fs.DirEntry, err error) error {
 	if err != nil {
 		return fmt.Errorf("walkFolders received error: %v", err)
@@ -59,10 +179,15 @@ func (vs *ViewSet) walkFolders(path string, d fs.DirEntry, err error) error {
 		if tempErr != nil {
 			return fmt.Errorf("walkFolders error when cloning template: %v\n", tempErr)
 		}
-		vs.ts[name], tempErr = t.New(name).ParseFS(vs.man.StaticFsys, path)
-		if err != nil {
+		vs.ts[name], tempErr = t.New(name).ParseFS(vs.fsys, path)
+		if tempErr != nil {
 			return fmt.Errorf("walkFolders error when template ParseFiles: %v\n", tempErr)
 		}
+
+		vs.pageLayouts[name], tempErr = readLayout(vs.fsys, path)
+		if tempErr != nil {
+			return fmt.Errorf("walkFolders error when reading layout: %v\n", tempErr)
+		}
 	}
 
 	return nil
@@ -92,37 +217,105 @@ func (vs *ViewSet) walkForBase(path string, d fs.DirEntry, err error) error {
 					"sLimitVar":    tLimitStringVar,
 					"extractHrefs": ExtractHrefs,
 					"tSince":       tPrettySince,
-				}).ParseFS(vs.man.StaticFsys, path)
+					"csrfField":    tCsrfField,
+				}).ParseFS(vs.fsys, path)
 			} else {
-				vs.baseTemplate, tempErr = vs.baseTemplate.ParseFS(vs.man.StaticFsys, path)
+				vs.baseTemplate, tempErr = vs.baseTemplate.ParseFS(vs.fsys, path)
 			}
 			if tempErr != nil {
 				return fmt.Errorf("walkForBase error from parsing template: %v", tempErr)
 			}
+
+			if strings.Contains(strings.ToLower(d.Name()), ".gohtml") {
+				vs.layoutParents[d.Name()], tempErr = readLayout(vs.fsys, path)
+				if tempErr != nil {
+					return fmt.Errorf("walkForBase error when reading layout: %v", tempErr)
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
+// GetT returns template by name (default template when not found) prepared for its layout, nil when default is missing too
 func (vs *ViewSet) GetT(name string) *template.Template {
-	t, ok := vs.ts[name]
-	if ok {
-		return t
-	} else {
-		log.Print("template not present in the ts map, using default")
-		tDef, present := vs.ts["default"]
-		if !present {
-			log.Fatal("ViewSet GetT: default template not found! Did you add 'default.html'?")
-			return nil
-		}
-		return tDef
+	vs.lock.RLock()
+	defer vs.lock.RUnlock()
+
+	t, _, err := vs.variant(name, "")
+	if err != nil {
+		log.Printf("ViewSet GetT: %v", err)
+		return nil
 	}
 
+	return t
 }
 
+// FireTemplate renders template in layout from its front-matter (base.gohtml when not set)
 func (vs *ViewSet) FireTemplate(name string, w http.ResponseWriter, ctnt *map[string]interface{}) error {
-	return vs.GetT(name).ExecuteTemplate(w, "base.gohtml", *ctnt)
+	return vs.FireTemplateLayout(name, "", w, ctnt)
+}
+
+// FireTemplateLayout renders template in layout (eg. "print.gohtml", "print" or NoLayout), empty layout works like FireTemplate
+func (vs *ViewSet) FireTemplateLayout(name string, layout string, w http.ResponseWriter, ctnt *map[string]interface{}) error {
+	t, entry, live, err := vs.resolve(name, layout)
+	if err != nil {
+		if live {
+			writeDevErrorPage(w, name, err)
+		} else {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+		return err
+	}
+
+	if !live {
+		return t.ExecuteTemplate(w, entry, *ctnt)
+	}
+
+	// in live mode output is buffered, so execution error replaces half rendered page
+	buf := &bytes.Buffer{}
+	err = t.ExecuteTemplate(buf, entry, *ctnt)
+	if err != nil {
+		writeDevErrorPage(w, name, err)
+		return err
+	}
+	_, err = buf.WriteTo(w)
+
+	return err
+}
+
+// resolve returns template prepared for layout and live mode flag (load error is returned in live mode).
+// Template is executed after lock is released (built templates are not modified), so slow client does not block reload.
+func (vs *ViewSet) resolve(name string, layout string) (t *template.Template, entry string, live bool, err error) {
+	vs.lock.RLock()
+	defer vs.lock.RUnlock()
+
+	if vs.live && vs.loadErr != nil {
+		return nil, "", true, vs.loadErr
+	}
+
+	t, entry, err = vs.variant(name, layout)
+	if err != nil {
+		err = fmt.Errorf("ViewSet FireTemplate: %w", err)
+	}
+
+	return t, entry, vs.live, err
+}
+
+var devErrorPage = template.Must(template.New("dev_error").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Template error</title></head>
+<body style="font-family: monospace; margin: 2em;">
+<h1 style="color: #b00;">Template error</h1>
+<p>Rendering <b>{{ .Name }}</b> failed:</p>
+<pre style="background: #fee; padding: 1em; white-space: pre-wrap;">{{ .Err }}</pre>
+<p>Templates are reloaded from disk (live static mode), refresh the page after fixing them.</p>
+</body></html>`))
+
+func writeDevErrorPage(w http.ResponseWriter, name string, err error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	devErrorPage.Execute(w, map[string]interface{}{"Name": name, "Err": err.Error()})
 }
 
 func tFuncIsNot(val interface{}) (ret bool) {
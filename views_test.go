@@ -0,0 +1,211 @@
+package manago
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// newTestViews loads templates from files (paths relative to "templates" directory)
+func newTestViews(t *testing.T, files map[string]string, live bool) (*ViewSet, error) {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys["templates/"+name] = &fstest.MapFile{Data: []byte(content)}
+	}
+
+	conf := Config{TemplatesPath: "templates", ForceLiveStatic: live}
+	man := &Manager{Config: conf, StaticFsys: fsys}
+	vs := &ViewSet{}
+
+	return vs, vs.Load(&conf, man)
+}
+
+func renderTemplate(vs *ViewSet, name string, layout string, ctnt map[string]interface{}) (*httptest.ResponseRecorder, error) {
+	w := httptest.NewRecorder()
+	err := vs.FireTemplateLayout(name, layout, w, &ctnt)
+	return w, err
+}
+
+var testBaseLayout = `<main>{{template "content" .}}</main>`
+
+func TestFireTemplate(t *testing.T) {
+	vs, err := newTestViews(t, map[string]string{
+		"base.gohtml":  testBaseLayout,
+		"home.html":    `{{define "content"}}Hi {{.Name}}{{end}}`,
+		"broken.html":  `{{define "content"}}{{.Name.Missing}}{{end}}`,
+		"default.html": `{{define "content"}}Default{{end}}`,
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		page string
+		code int
+		body string
+		err  bool
+	}{
+		{"page in base layout", "home", 200, "<main>Hi John</main>", false},
+		{"missing page uses default", "missing", 200, "<main>Default</main>", false},
+		{"execution error", "broken", 200, "<main>", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := renderTemplate(vs, tt.page, "", map[string]interface{}{"Name": "John"})
+			if (err != nil) != tt.err {
+				t.Errorf("FireTemplate error = %v, want error %v", err, tt.err)
+			}
+			if w.Code != tt.code || w.Body.String() != tt.body {
+				t.Errorf("FireTemplate = %d %q, want %d %q", w.Code, w.Body.String(), tt.code, tt.body)
+			}
+		})
+	}
+}
+
+func TestFireTemplateLiveErrors(t *testing.T) {
+	vs, err := newTestViews(t, map[string]string{
+		"base.gohtml": testBaseLayout,
+		"broken.html": `{{define "content"}}before {{.Name.Missing}}{{end}}`,
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := renderTemplate(vs, "broken", "", map[string]interface{}{"Name": "John"})
+	if err == nil || w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "before") || !strings.Contains(w.Body.String(), "Template error") {
+		t.Errorf("execution error in live mode = %d %q (%v), want developer error page", w.Code, w.Body.String(), err)
+	}
+
+	vs, err = newTestViews(t, map[string]string{
+		"base.gohtml": testBaseLayout,
+		"home.html":   `{{define "content"}}{{.Name}{{end}}`,
+	}, true)
+	if err == nil {
+		t.Fatal("Load accepted broken template")
+	}
+
+	w, err = renderTemplate(vs, "home", "", nil)
+	if err == nil || w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Template error") {
+		t.Errorf("load error in live mode = %d %q (%v), want developer error page", w.Code, w.Body.String(), err)
+	}
+}
+
+// blockingWriter blocks first write until released
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (bw *blockingWriter) Write(b []byte) (int, error) {
+	select {
+	case <-bw.writing:
+	default:
+		close(bw.writing)
+		<-bw.release
+	}
+	return bw.ResponseRecorder.Write(b)
+}
+
+func TestFireTemplateSlowClientDoesNotBlockLoad(t *testing.T) {
+	files := map[string]string{"base.gohtml": testBaseLayout, "home.html": `{{define "content"}}Hi{{end}}`}
+	vs, err := newTestViews(t, files, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &blockingWriter{httptest.NewRecorder(), make(chan struct{}), make(chan struct{})}
+	go vs.FireTemplate("home", w, &map[string]interface{}{})
+	<-w.writing
+	defer close(w.release)
+
+	loaded := make(chan error)
+	go func() {
+		conf := Config{TemplatesPath: "templates"}
+		loaded <- vs.Load(&conf, vs.man)
+	}()
+
+	select {
+	case err = <-loaded:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Load blocked by template written to slow client")
+	}
+}
+
+func writeTestTemplates(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, "templates", name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWatchLoopAfterReloadStaticFS(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	writeTestTemplates(t, first, map[string]string{"base.gohtml": testBaseLayout, "home.html": `{{define "content"}}first{{end}}`})
+	writeTestTemplates(t, second, map[string]string{"base.gohtml": testBaseLayout, "home.html": `{{define "content"}}second{{end}}`})
+
+	man := &Manager{
+		Config: Config{StaticPath: first, TemplatesPath: "templates", WebStaticPath: "web", ForceLiveStatic: true},
+		router: httprouter.New(),
+		Views:  &ViewSet{},
+	}
+	man.StaticFsys = man.staticFS(nil)
+	err := man.Views.Load(&man.Config, man)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	conf := man.Config
+	go func() {
+		defer close(stopped)
+		man.Views.watchLoop(stop, conf)
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	// live static serves StaticPath, so reloaded FS comes from changed config
+	man.Config.StaticPath = second
+	err = man.ReloadStaticFS(os.DirFS(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	writeTestTemplates(t, second, map[string]string{"home.html": `{{define "content"}}changed{{end}}`})
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(second, "templates", "home.html"), later, later)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w, err := renderTemplate(man.Views, "home", "", map[string]interface{}{})
+		if err == nil && w.Body.String() == "<main>changed</main>" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("watchLoop did not reload changed template of new FS, rendered %q (%v)", w.Body.String(), err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}